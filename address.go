@@ -76,6 +76,15 @@ func (a Address) Append(childNodeAddr uint8) Address {
 	return result
 }
 
+// Last returns the local address of the node within its parent (the last non-zero element).
+// Returns 0 for the interface address.
+func (a Address) Last() uint8 {
+	if l := a.GetLength(); l > 0 {
+		return a[l-1]
+	}
+	return 0
+}
+
 // Returns true if the given address is not the interface address.
 func (a Address) HasParent() bool {
 	return a[0] != 0
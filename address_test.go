@@ -48,3 +48,10 @@ func TestAddressEqualsOr(t *testing.T) {
 	assert.False(t, a.EqualsOrContains(empty))
 	assert.True(t, empty.EqualsOrContains(a))
 }
+
+func TestAddressLast(t *testing.T) {
+	assert.Equal(t, uint8(0), InterfaceAddress().Last())
+	assert.Equal(t, uint8(1), MustNewAddress(1).Last())
+	assert.Equal(t, uint8(3), MustNewAddress(1, 2, 3).Last())
+	assert.Equal(t, uint8(4), MustNewAddress(1, 2, 3, 4).Last())
+}
@@ -0,0 +1,116 @@
+package bidib
+
+import "strconv"
+
+// Current as reported by boosters and occupancy detectors.
+// The value uses a piecewise linear encoding that is also used
+// by FEATURE_BST_AMPERE.
+type Current uint8
+
+const (
+	// Current value indicating an overcurrent situation
+	CurrentOverflow Current = 254
+	// Current value indicating that no measurement is available
+	CurrentUnknown Current = 255
+)
+
+// MilliAmps returns the current in mA.
+// Returns value, valid. Valid is false for overcurrent, reserved and unknown values.
+func (c Current) MilliAmps() (int, bool) {
+	switch {
+	case c <= 15:
+		return int(c), true
+	case c <= 63:
+		return int(c-12) * 4, true
+	case c <= 127:
+		return int(c-51) * 16, true
+	case c <= 191:
+		return int(c-108) * 64, true
+	case c <= 250:
+		return int(c-171) * 256, true
+	default:
+		return 0, false
+	}
+}
+
+// IsOverflow returns true if the current exceeds the measurement range.
+func (c Current) IsOverflow() bool {
+	return c == CurrentOverflow
+}
+
+// IsUnknown returns true if the current is not known (reserved or no measurement).
+func (c Current) IsUnknown() bool {
+	return c >= 251 && c != CurrentOverflow
+}
+
+// String converts the current to a human readable string
+func (c Current) String() string {
+	if mA, ok := c.MilliAmps(); ok {
+		return strconv.Itoa(mA) + " mA"
+	}
+	if c.IsOverflow() {
+		return "overcurrent"
+	}
+	if c == CurrentUnknown {
+		return "unknown"
+	}
+	return "reserved"
+}
+
+// Voltage as reported by boosters (unit 100mV)
+type Voltage uint8
+
+// MilliVolts returns the voltage in mV.
+// Returns value, valid. Valid is false for reserved and unknown values.
+func (v Voltage) MilliVolts() (int, bool) {
+	if v <= 250 {
+		return int(v) * 100, true
+	}
+	return 0, false
+}
+
+// IsUnknown returns true if the voltage is not known (reserved or no measurement).
+func (v Voltage) IsUnknown() bool {
+	return v > 250
+}
+
+// String converts the voltage to a human readable string
+func (v Voltage) String() string {
+	if mV, ok := v.MilliVolts(); ok {
+		return strconv.Itoa(mV) + " mV"
+	}
+	if v == 255 {
+		return "unknown"
+	}
+	return "reserved"
+}
+
+// Temperature as reported by boosters (unit °C, two's complement for negative values)
+type Temperature uint8
+
+// Celsius returns the temperature in °C.
+// Returns value, valid. Valid is false for reserved values.
+func (t Temperature) Celsius() (int, bool) {
+	switch {
+	case t <= 127:
+		return int(t), true
+	case t <= 225:
+		return 0, false
+	default:
+		return int(t) - 256, true
+	}
+}
+
+// IsUnknown returns true if the temperature is not known (reserved value).
+func (t Temperature) IsUnknown() bool {
+	_, ok := t.Celsius()
+	return !ok
+}
+
+// String converts the temperature to a human readable string
+func (t Temperature) String() string {
+	if c, ok := t.Celsius(); ok {
+		return strconv.Itoa(c) + " C"
+	}
+	return "reserved"
+}
@@ -0,0 +1,56 @@
+package bidib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentMilliAmps(t *testing.T) {
+	expect := func(c Current, mA int) {
+		v, ok := c.MilliAmps()
+		assert.True(t, ok, "current %d", c)
+		assert.Equal(t, mA, v, "current %d", c)
+	}
+	expect(0, 0)
+	expect(15, 15)
+	expect(16, 16)
+	expect(63, 204)
+	expect(64, 208)
+	expect(127, 1216)
+	expect(128, 1280)
+	expect(191, 5312)
+	expect(192, 5376)
+	expect(250, 20224)
+
+	_, ok := Current(251).MilliAmps()
+	assert.False(t, ok)
+	assert.True(t, Current(254).IsOverflow())
+	assert.False(t, Current(254).IsUnknown())
+	assert.True(t, Current(255).IsUnknown())
+	assert.Equal(t, "208 mA", Current(64).String())
+	assert.Equal(t, "overcurrent", Current(254).String())
+	assert.Equal(t, "unknown", Current(255).String())
+}
+
+func TestVoltageMilliVolts(t *testing.T) {
+	v, ok := Voltage(160).MilliVolts()
+	assert.True(t, ok)
+	assert.Equal(t, 16000, v)
+	assert.True(t, Voltage(255).IsUnknown())
+	assert.Equal(t, "16000 mV", Voltage(160).String())
+}
+
+func TestTemperatureCelsius(t *testing.T) {
+	c, ok := Temperature(45).Celsius()
+	assert.True(t, ok)
+	assert.Equal(t, 45, c)
+	c, ok = Temperature(255).Celsius()
+	assert.True(t, ok)
+	assert.Equal(t, -1, c)
+	c, ok = Temperature(226).Celsius()
+	assert.True(t, ok)
+	assert.Equal(t, -30, c)
+	assert.True(t, Temperature(200).IsUnknown())
+	assert.Equal(t, "-1 C", Temperature(255).String())
+}
@@ -10,6 +10,17 @@ import "strings"
 // Even if in the current configuration no objects are available, it should register the class and yield 0 for the count.
 type ClassID uint8
 
+const (
+	BIDIB_CLASS_NONE      ClassID = 0      // Not specific to any class
+	BIDIB_CLASS_SWITCHING ClassID = 1 << 0 // Node contains switching functions, e.g. light animation
+	BIDIB_CLASS_BOOSTER   ClassID = 1 << 1 // Node contains booster functions
+	BIDIB_CLASS_ACCESSORY ClassID = 1 << 2 // Node contains accessory control functions
+	BIDIB_CLASS_DCC_PROG  ClassID = 1 << 3 // Node contains DCC signal generator for programming
+	BIDIB_CLASS_DCC_MAIN  ClassID = 1 << 4 // Node contains DCC signal generator for driving, switching
+	BIDIB_CLASS_OCCUPANCY ClassID = 1 << 6 // Node contains occupancy detection functions
+	BIDIB_CLASS_BRIDGE    ClassID = 1 << 7 // Node contains sub-nodes (is an interface itself)
+)
+
 // Bit 7	1: Node contains sub-nodes (is an interface itself)
 func (cid ClassID) HasSubNodes() bool {
 	return (cid & (1 << 7)) != 0
@@ -4,6 +4,13 @@ import (
 	"fmt"
 )
 
+const (
+	// Highest function number that can be send with MSG_CS_DRIVE
+	MaxDriveFunction = 28
+	// Highest function number supported by DCC
+	MaxDccFunction = 68
+)
+
 // DCC Flags
 // Index 0 = FL (lights)
 // Index 1 = F1
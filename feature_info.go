@@ -0,0 +1,182 @@
+package bidib
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Units of feature values
+const (
+	FeatureUnitNone  = ""
+	FeatureUnitVolt  = "V"
+	FeatureUnit2ms   = "2ms"
+	FeatureUnit10ms  = "10ms"
+	FeatureUnit100ms = "100ms"
+)
+
+// FeatureInfo describes the meaning and valid values of a feature.
+type FeatureInfo struct {
+	// Class of nodes this feature belongs to
+	Class ClassID
+	// Human readable description
+	Description string
+	// Unit of the value (FeatureUnitNone if not applicable)
+	Unit string
+	// Valid range of the value (inclusive)
+	Min, Max uint8
+	// If set, the value is reported by the node and cannot be set by the host
+	ReadOnly bool
+	// Decode converts a specially coded value into a human readable string.
+	// Nil for plain values.
+	Decode func(value uint8) string
+}
+
+// Format converts the given value into a human readable string.
+func (fi FeatureInfo) Format(value uint8) string {
+	if fi.Decode != nil {
+		return fi.Decode(value)
+	}
+	switch fi.Unit {
+	case FeatureUnitNone:
+		return strconv.Itoa(int(value))
+	case FeatureUnit2ms:
+		return strconv.Itoa(int(value)*2) + " ms"
+	case FeatureUnit10ms:
+		return strconv.Itoa(int(value)*10) + " ms"
+	case FeatureUnit100ms:
+		return strconv.Itoa(int(value)*100) + " ms"
+	default:
+		return strconv.Itoa(int(value)) + " " + fi.Unit
+	}
+}
+
+// Validate checks if the given value can be set for the feature.
+func (fi FeatureInfo) Validate(value uint8) error {
+	if fi.ReadOnly {
+		return fmt.Errorf("feature is read-only")
+	}
+	if value < fi.Min || value > fi.Max {
+		return fmt.Errorf("value %d out of range [%d-%d]", value, fi.Min, fi.Max)
+	}
+	return nil
+}
+
+// Info returns the catalogue entry of the feature.
+// Returns info, found
+func (id FeatureID) Info() (FeatureInfo, bool) {
+	info, found := featureInfos[id]
+	return info, found
+}
+
+// Helpers to build the catalogue
+func readOnlyFeature(class ClassID, description string) FeatureInfo {
+	return FeatureInfo{Class: class, Description: description, Max: 255, ReadOnly: true}
+}
+
+func boolFeature(class ClassID, description string) FeatureInfo {
+	return FeatureInfo{Class: class, Description: description, Max: 1}
+}
+
+func readOnlyBoolFeature(class ClassID, description string) FeatureInfo {
+	return FeatureInfo{Class: class, Description: description, Max: 1, ReadOnly: true}
+}
+
+func valueFeature(class ClassID, description, unit string, min, max uint8) FeatureInfo {
+	return FeatureInfo{Class: class, Description: description, Unit: unit, Min: min, Max: max}
+}
+
+// decodeCurrentFeature decodes a value with the special current coding.
+func decodeCurrentFeature(value uint8) string {
+	return Current(value).String()
+}
+
+// Catalogue of all known features
+var featureInfos = map[FeatureID]FeatureInfo{
+	//-- occupancy
+	FEATURE_BM_SIZE:                  readOnlyFeature(BIDIB_CLASS_OCCUPANCY, "Number of occupancy detectors"),
+	FEATURE_BM_ON:                    boolFeature(BIDIB_CLASS_OCCUPANCY, "Occupancy detection on"),
+	FEATURE_BM_SECACK_AVAILABLE:      readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "Secure ack available"),
+	FEATURE_BM_SECACK_ON:             valueFeature(BIDIB_CLASS_OCCUPANCY, "Secure ack interval (0: off)", FeatureUnit10ms, 0, 255),
+	FEATURE_BM_CURMEAS_AVAILABLE:     readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "Current measurement available"),
+	FEATURE_BM_CURMEAS_INTERVAL:      valueFeature(BIDIB_CLASS_OCCUPANCY, "Current measurement interval", FeatureUnit10ms, 0, 255),
+	FEATURE_BM_DC_MEAS_AVAILABLE:     readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "DC measurement available"),
+	FEATURE_BM_DC_MEAS_ON:            boolFeature(BIDIB_CLASS_OCCUPANCY, "DC measurement on"),
+	FEATURE_BM_ADDR_DETECT_AVAILABLE: readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "Address detection available"),
+	FEATURE_BM_ADDR_DETECT_ON:        boolFeature(BIDIB_CLASS_OCCUPANCY, "Address detection on"),
+	//-- bidi detection
+	FEATURE_BM_ADDR_AND_DIR:       readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "Addresses contain direction"),
+	FEATURE_BM_ISTSPEED_AVAILABLE: readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "Speed messages available"),
+	FEATURE_BM_ISTSPEED_INTERVAL:  valueFeature(BIDIB_CLASS_OCCUPANCY, "Speed update interval", FeatureUnit10ms, 0, 255),
+	FEATURE_BM_CV_AVAILABLE:       readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "CV readback available"),
+	FEATURE_BM_CV_ON:              boolFeature(BIDIB_CLASS_OCCUPANCY, "CV readback on"),
+	//-- booster
+	FEATURE_BST_VOLT_ADJUSTABLE:     readOnlyBoolFeature(BIDIB_CLASS_BOOSTER, "Output voltage adjustable"),
+	FEATURE_BST_VOLT:                valueFeature(BIDIB_CLASS_BOOSTER, "Output voltage", FeatureUnitVolt, 0, 255),
+	FEATURE_BST_CUTOUT_AVAIALABLE:   readOnlyBoolFeature(BIDIB_CLASS_BOOSTER, "Railcom cutout available"),
+	FEATURE_BST_CUTOUT_ON:           boolFeature(BIDIB_CLASS_BOOSTER, "Railcom cutout on"),
+	FEATURE_BST_TURNOFF_TIME:        valueFeature(BIDIB_CLASS_BOOSTER, "Turn off time on short", FeatureUnit2ms, 0, 255),
+	FEATURE_BST_INRUSH_TURNOFF_TIME: valueFeature(BIDIB_CLASS_BOOSTER, "Turn off time on short after power up", FeatureUnit2ms, 0, 255),
+	FEATURE_BST_AMPERE_ADJUSTABLE:   readOnlyBoolFeature(BIDIB_CLASS_BOOSTER, "Output current adjustable"),
+	FEATURE_BST_AMPERE: {
+		Class:       BIDIB_CLASS_BOOSTER,
+		Description: "Output current limit",
+		Max:         250,
+		Decode:      decodeCurrentFeature,
+	},
+	FEATURE_BST_CURMEAS_INTERVAL:    valueFeature(BIDIB_CLASS_BOOSTER, "Current measurement interval (0: off)", FeatureUnit10ms, 0, 255),
+	FEATURE_BST_CV_AVAILABLE:        readOnlyBoolFeature(BIDIB_CLASS_BOOSTER, "CV readback available (deprecated)"),
+	FEATURE_BST_CV_ON:               boolFeature(BIDIB_CLASS_BOOSTER, "CV readback on (deprecated)"),
+	FEATURE_BST_INHIBIT_AUTOSTART:   boolFeature(BIDIB_CLASS_BOOSTER, "Inhibit automatic start on DCC input"),
+	FEATURE_BST_INHIBIT_LOCAL_ONOFF: boolFeature(BIDIB_CLASS_BOOSTER, "Inhibit local on/off keys"),
+	//-- bidi detection
+	FEATURE_BM_DYN_STATE_INTERVAL: valueFeature(BIDIB_CLASS_OCCUPANCY, "Dynamic state interval", FeatureUnit100ms, 0, 255),
+	FEATURE_BM_RCPLUS_AVAILABLE:   readOnlyBoolFeature(BIDIB_CLASS_OCCUPANCY, "RailcomPlus available"),
+	//-- occupancy
+	FEATURE_BM_TIMESTAMP_ON: boolFeature(BIDIB_CLASS_OCCUPANCY, "Occupancy with timestamp"),
+	//-- bidi detection
+	FEATURE_BM_POSITION_ON:     boolFeature(BIDIB_CLASS_OCCUPANCY, "Position messages on"),
+	FEATURE_BM_POSITION_SECACK: valueFeature(BIDIB_CLASS_OCCUPANCY, "Secure position ack interval (0: off)", FeatureUnit10ms, 0, 255),
+	//-- accessory
+	FEATURE_ACCESSORY_COUNT:       readOnlyFeature(BIDIB_CLASS_ACCESSORY, "Number of accessories"),
+	FEATURE_ACCESSORY_SURVEILLED:  boolFeature(BIDIB_CLASS_ACCESSORY, "Announce manual operation"),
+	FEATURE_ACCESSORY_MACROMAPPED: valueFeature(BIDIB_CLASS_ACCESSORY, "Number of aspects mapped to macros", FeatureUnitNone, 0, 255),
+	//-- control
+	FEATURE_CTRL_INPUT_COUNT:              readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of inputs"),
+	FEATURE_CTRL_INPUT_NOTIFY:             boolFeature(BIDIB_CLASS_SWITCHING, "Report input changes"),
+	FEATURE_CTRL_SWITCH_COUNT:             readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of switch ports"),
+	FEATURE_CTRL_LIGHT_COUNT:              readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of light ports"),
+	FEATURE_CTRL_SERVO_COUNT:              readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of servo ports"),
+	FEATURE_CTRL_SOUND_COUNT:              readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of sound ports"),
+	FEATURE_CTRL_MOTOR_COUNT:              readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of motor ports"),
+	FEATURE_CTRL_ANALOGOUT_COUNT:          readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of analog ports"),
+	FEATURE_CTRL_STRETCH_DIMM:             valueFeature(BIDIB_CLASS_SWITCHING, "Time stretch for dimming", FeatureUnitNone, 1, 255),
+	FEATURE_CTRL_BACKLIGHT_COUNT:          readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of backlight ports"),
+	FEATURE_CTRL_MAC_LEVEL:                readOnlyFeature(BIDIB_CLASS_SWITCHING, "Supported macro level"),
+	FEATURE_CTRL_MAC_SAVE:                 readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of permanent macro storage places"),
+	FEATURE_CTRL_MAC_COUNT:                readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of macros"),
+	FEATURE_CTRL_MAC_SIZE:                 readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of entries per macro"),
+	FEATURE_CTRL_MAC_START_MAN:            boolFeature(BIDIB_CLASS_SWITCHING, "Manual macro start"),
+	FEATURE_CTRL_MAC_START_DCC:            boolFeature(BIDIB_CLASS_SWITCHING, "DCC macro start"),
+	FEATURE_CTRL_PORT_QUERY_AVAILABLE:     readOnlyBoolFeature(BIDIB_CLASS_SWITCHING, "Port queries available"),
+	FEATURE_SWITCH_CONFIG_AVAILABLE:       readOnlyBoolFeature(BIDIB_CLASS_SWITCHING, "Switch port configuration available (deprecated)"),
+	FEATURE_CTRL_PORT_FLAT_MODEL:          readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of ports in flat port model"),
+	FEATURE_CTRL_PORT_FLAT_MODEL_EXTENDED: readOnlyFeature(BIDIB_CLASS_SWITCHING, "Number of ports in flat port model (high byte)"),
+	//-- dcc gen
+	FEATURE_GEN_SPYMODE:             boolFeature(BIDIB_CLASS_DCC_MAIN, "Watch bidib handsets"),
+	FEATURE_GEN_WATCHDOG:            valueFeature(BIDIB_CLASS_DCC_MAIN, "Watchdog timeout (0: off)", FeatureUnit100ms, 0, 255),
+	FEATURE_GEN_DRIVE_ACK:           valueFeature(BIDIB_CLASS_DCC_MAIN, "Drive acknowledge level", FeatureUnitNone, 0, 255),
+	FEATURE_GEN_SWITCH_ACK:          valueFeature(BIDIB_CLASS_DCC_MAIN, "Switch acknowledge level", FeatureUnitNone, 0, 255),
+	FEATURE_GEN_LOK_DB_SIZE:         readOnlyFeature(BIDIB_CLASS_DCC_MAIN, "Size of loco database"),
+	FEATURE_GEN_LOK_DB_STRING:       readOnlyFeature(BIDIB_CLASS_DCC_MAIN, "Length of loco database names"),
+	FEATURE_GEN_POM_REPEAT:          valueFeature(BIDIB_CLASS_DCC_MAIN, "POM repeat count", FeatureUnitNone, 0, 255),
+	FEATURE_GEN_DRIVE_BUS:           boolFeature(BIDIB_CLASS_DCC_MAIN, "Drive the DCC bus"),
+	FEATURE_GEN_LOK_LOST_DETECT:     boolFeature(BIDIB_CLASS_DCC_MAIN, "Announce lost locos"),
+	FEATURE_GEN_NOTIFY_DRIVE_MANUAL: boolFeature(BIDIB_CLASS_DCC_MAIN, "Report manual operation"),
+	FEATURE_GEN_START_STATE:         boolFeature(BIDIB_CLASS_DCC_MAIN, "Power up state on"),
+	FEATURE_GEN_RCPLUS_AVAILABLE:    readOnlyBoolFeature(BIDIB_CLASS_DCC_MAIN, "RailcomPlus available"),
+	//-- general
+	FEATURE_STRING_SIZE:       readOnlyFeature(BIDIB_CLASS_NONE, "Length of user strings (0: none)"),
+	FEATURE_RELEVANT_PID_BITS: readOnlyFeature(BIDIB_CLASS_NONE, "Number of product ID bits in unique ID"),
+	FEATURE_FW_UPDATE_MODE:    readOnlyFeature(BIDIB_CLASS_NONE, "Firmware update mode (0: none, 1: intel hex)"),
+	FEATURE_EXTENSION:         readOnlyFeature(BIDIB_CLASS_NONE, "Reserved for future expansion"),
+}
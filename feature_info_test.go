@@ -0,0 +1,38 @@
+package bidib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureInfo(t *testing.T) {
+	info, found := FEATURE_GEN_WATCHDOG.Info()
+	assert.True(t, found)
+	assert.Equal(t, BIDIB_CLASS_DCC_MAIN, info.Class)
+	assert.Equal(t, FeatureUnit100ms, info.Unit)
+	assert.Equal(t, "2000 ms", info.Format(20))
+	assert.NoError(t, info.Validate(20))
+
+	info, found = FEATURE_BST_TURNOFF_TIME.Info()
+	assert.True(t, found)
+	assert.Equal(t, "10 ms", info.Format(5))
+
+	info, found = FEATURE_BST_AMPERE.Info()
+	assert.True(t, found)
+	assert.Equal(t, "208 mA", info.Format(64))
+	assert.Error(t, info.Validate(254))
+
+	info, found = FEATURE_BM_SIZE.Info()
+	assert.True(t, found)
+	assert.True(t, info.ReadOnly)
+	assert.Error(t, info.Validate(16))
+
+	info, found = FEATURE_BM_ON.Info()
+	assert.True(t, found)
+	assert.NoError(t, info.Validate(1))
+	assert.Error(t, info.Validate(2))
+
+	_, found = FeatureID(200).Info()
+	assert.False(t, found)
+}
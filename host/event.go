@@ -3,31 +3,164 @@ package host
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 )
 
 type EventHandler[T any] func(T)
 
-// Event registration & callback
+// OverflowPolicy defines what happens when an event is invoked while the
+// queue of a subscriber is full.
+type OverflowPolicy uint8
+
+const (
+	// Grow the queue as needed, no events are dropped and the code invoking
+	// the event never waits (default)
+	OverflowUnbounded OverflowPolicy = iota
+	// Drop the oldest queued event to make room for the new event
+	OverflowDropOldest
+	// Drop the new event
+	OverflowDropNewest
+	// Wait until there is room in the queue.
+	// The code invoking the event waits for the handler. For host events this
+	// is the message queue, so a slow handler stalls the entire host.
+	// Host.Subscribe does not support this policy and uses OverflowUnbounded instead.
+	OverflowBlock
+)
+
+const (
+	defaultEventQueueSize = 64
+)
+
+// EventOptions control the delivery of events to a single handler.
+type EventOptions struct {
+	// Maximum number of events queued for the handler. Defaults to 64.
+	QueueSize int
+	// What to do when the queue is full. Defaults to OverflowUnbounded.
+	Overflow OverflowPolicy
+}
+
+// Event registration & callback.
+// Every handler receives its events in order, on its own goroutine.
 type Event[T any] struct {
 	mutex         sync.RWMutex
+	running       sync.WaitGroup
 	lastHandlerID int
-	handlers      map[int]EventHandler[T]
+	handlers      map[int]*eventSubscriber[T]
+	// Set once closeAll has been called, no handlers are added after that
+	closed bool
+}
+
+// eventSubscriber delivers events to a single handler.
+type eventSubscriber[T any] struct {
+	handler EventHandler[T]
+	// If set, only events accepted by the filter are delivered
+	filter func(T) bool
+	// If set, called when the subscriber has stopped
+	onStop   func()
+	overflow OverflowPolicy
+	queue    chan T
+	done     chan struct{}
+	stopOnce sync.Once
+	// If set, queued events are delivered after stop
+	drain bool
+	// Events that did not fit in the queue (OverflowUnbounded only)
+	backlogMutex sync.Mutex
+	backlog      []T
+	// Number of dropped events since the last marker (accessed atomically)
+	missed uint64
+	// If set, creates an event that is delivered before the next event,
+	// after one or more events have been dropped.
+	marker func(missed uint64) T
 }
 
 // Register an event handler.
 // To unregister, call the returned cancel function.
-func (e *Event[T]) Register(handler EventHandler[T]) context.CancelFunc {
+// Handlers registered after the event has been closed are never called.
+func (e *Event[T]) Register(handler EventHandler[T], opts ...EventOptions) context.CancelFunc {
+	return e.add(newEventSubscriber(handler, opts...))
+}
+
+// Subscribe returns a channel that receives all events accepted by the given filter
+// (nil accepts all), until the given context is canceled.
+// The channel is closed when the subscription ends, or right away when the
+// event has been closed.
+func (e *Event[T]) Subscribe(ctx context.Context, filter func(T) bool, opts ...EventOptions) <-chan T {
+	return e.subscribe(ctx, filter, nil, opts...)
+}
+
+// subscribe implements Subscribe.
+// If marker is set, it is used to create an event that tells the subscriber
+// that events were dropped.
+func (e *Event[T]) subscribe(ctx context.Context, filter func(T) bool, marker func(uint64) T, opts ...EventOptions) <-chan T {
+	out := make(chan T)
+	s := newEventSubscriber(func(value T) {
+		select {
+		case out <- value:
+		case <-ctx.Done():
+		}
+	}, opts...)
+	s.filter = filter
+	s.marker = marker
+	s.onStop = func() { close(out) }
+	cancel := e.add(s)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-s.done:
+			// Subscription canceled or event closed
+		}
+	}()
+	return out
+}
+
+// newEventSubscriber creates a subscriber for the given handler.
+func newEventSubscriber[T any](handler EventHandler[T], opts ...EventOptions) *eventSubscriber[T] {
+	var o EventOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = defaultEventQueueSize
+	}
+	s := &eventSubscriber[T]{
+		handler:  handler,
+		overflow: o.Overflow,
+		queue:    make(chan T, o.QueueSize),
+		done:     make(chan struct{}),
+	}
+	return s
+}
+
+// add the given subscriber and start its delivery loop.
+// To remove it, call the returned cancel function.
+// If the event is closed, the subscriber is stopped right away.
+func (e *Event[T]) add(s *eventSubscriber[T]) context.CancelFunc {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
+	if e.closed {
+		s.stop()
+		if s.onStop != nil {
+			s.onStop()
+		}
+		return func() {}
+	}
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		s.run()
+	}()
+
 	e.lastHandlerID++
 	id := e.lastHandlerID
 	if e.handlers == nil {
-		e.handlers = make(map[int]EventHandler[T])
+		e.handlers = make(map[int]*eventSubscriber[T])
 	}
-	e.handlers[id] = handler
+	e.handlers[id] = s
 
 	return func() {
+		s.stop()
 		e.mutex.Lock()
 		defer e.mutex.Unlock()
 		delete(e.handlers, id)
@@ -39,7 +172,137 @@ func (e *Event[T]) Invoke(value T) {
 	e.mutex.RLock()
 	defer e.mutex.RUnlock()
 
-	for _, h := range e.handlers {
-		go h(value)
+	for _, s := range e.handlers {
+		s.deliver(value)
+	}
+}
+
+// closeAll stops all handlers after delivering the events that are already queued.
+// Waits until all handlers have stopped, or the given context expires.
+// Handlers added after this call are never started.
+func (e *Event[T]) closeAll(ctx context.Context) {
+	e.mutex.Lock()
+	e.closed = true
+	for id, s := range e.handlers {
+		s.drain = true
+		s.stop()
+		delete(e.handlers, id)
+	}
+	e.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// run calls the handler for all queued events until stopped.
+func (s *eventSubscriber[T]) run() {
+	if s.onStop != nil {
+		defer s.onStop()
+	}
+	for {
+		select {
+		case value := <-s.queue:
+			s.handle(value)
+		case <-s.done:
+			if s.drain {
+				// Deliver remaining events
+				for {
+					select {
+					case value := <-s.queue:
+						s.handle(value)
+					default:
+						return
+					}
+				}
+			}
+			return
+		}
+	}
+}
+
+// handle calls the handler for the given value, which was just taken from
+// the queue.
+func (s *eventSubscriber[T]) handle(value T) {
+	s.refill()
+	if s.marker != nil {
+		if missed := atomic.SwapUint64(&s.missed, 0); missed > 0 {
+			s.handler(s.marker(missed))
+		}
+	}
+	s.handler(value)
+}
+
+// refill moves events from the backlog into the queue, as far as they fit.
+func (s *eventSubscriber[T]) refill() {
+	s.backlogMutex.Lock()
+	defer s.backlogMutex.Unlock()
+	for len(s.backlog) > 0 {
+		select {
+		case s.queue <- s.backlog[0]:
+			var zero T
+			s.backlog[0] = zero
+			s.backlog = s.backlog[1:]
+		default:
+			return
+		}
+	}
+}
+
+// stop the delivery of events.
+func (s *eventSubscriber[T]) stop() {
+	s.stopOnce.Do(func() { close(s.done) })
+}
+
+// deliver puts the given value in the queue, respecting the overflow policy.
+func (s *eventSubscriber[T]) deliver(value T) {
+	if s.filter != nil && !s.filter(value) {
+		return
+	}
+	switch s.overflow {
+	case OverflowBlock:
+		select {
+		case s.queue <- value:
+		case <-s.done:
+		}
+	case OverflowDropNewest:
+		select {
+		case s.queue <- value:
+		default:
+			// Queue full, drop newest
+			atomic.AddUint64(&s.missed, 1)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.queue <- value:
+				return
+			default:
+				// Queue full, drop oldest
+				select {
+				case <-s.queue:
+					atomic.AddUint64(&s.missed, 1)
+				default:
+				}
+			}
+		}
+	default:
+		s.backlogMutex.Lock()
+		defer s.backlogMutex.Unlock()
+		if len(s.backlog) == 0 {
+			select {
+			case s.queue <- value:
+				return
+			default:
+			}
+		}
+		// Queue full, keep in backlog until there is room
+		s.backlog = append(s.backlog, value)
 	}
 }
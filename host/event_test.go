@@ -0,0 +1,106 @@
+package host
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSubscribeAfterClose(t *testing.T) {
+	var e Event[int]
+	e.closeAll(context.Background())
+	ch := e.Subscribe(context.Background(), nil)
+	_, ok := <-ch
+	assert.False(t, ok, "channel must be closed")
+	called := false
+	e.Register(func(int) { called = true })
+	e.Invoke(1)
+	assert.False(t, called)
+}
+
+func TestEventCloseEndsSubscription(t *testing.T) {
+	var e Event[int]
+	ch := e.Subscribe(context.Background(), nil)
+	e.Invoke(1)
+	assert.Equal(t, 1, <-ch)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go e.closeAll(ctx)
+	_, ok := <-ch
+	assert.False(t, ok, "channel must be closed")
+}
+
+func TestEventResyncMarker(t *testing.T) {
+	var e Event[int]
+	var received []int
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := newEventSubscriber(func(value int) {
+		received = append(received, value)
+		if value == 1 {
+			// Block the handler until all events are invoked
+			close(started)
+			<-release
+		}
+	}, EventOptions{QueueSize: 2, Overflow: OverflowDropOldest})
+	s.marker = func(missed uint64) int { return -int(missed) }
+	e.add(s)
+	e.Invoke(1)
+	<-started
+	for i := 2; i <= 5; i++ {
+		e.Invoke(i)
+	}
+	close(release)
+	e.closeAll(context.Background())
+	// 1 is in the handler, 2 & 3 are dropped, 4 & 5 remain
+	assert.Equal(t, []int{1, -2, 4, 5}, received)
+}
+
+func TestEventRegisterDefaultKeepsAllEvents(t *testing.T) {
+	var e Event[int]
+	var received []int
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e.Register(func(value int) {
+		received = append(received, value)
+		if value == 0 {
+			close(started)
+			<-release
+		}
+	})
+	var expected []int
+	for i := 0; i < defaultEventQueueSize*2; i++ {
+		e.Invoke(i)
+		expected = append(expected, i)
+		if i == 0 {
+			<-started
+		}
+	}
+	close(release)
+	e.closeAll(context.Background())
+	assert.Equal(t, expected, received)
+}
+
+func TestEventUnbounded(t *testing.T) {
+	var e Event[int]
+	var mutex sync.Mutex
+	var received []int
+	release := make(chan struct{})
+	e.Register(func(value int) {
+		<-release
+		mutex.Lock()
+		received = append(received, value)
+		mutex.Unlock()
+	}, EventOptions{QueueSize: 2, Overflow: OverflowUnbounded})
+	var expected []int
+	for i := 0; i < 100; i++ {
+		e.Invoke(i)
+		expected = append(expected, i)
+	}
+	close(release)
+	e.closeAll(context.Background())
+	assert.Equal(t, expected, received)
+}
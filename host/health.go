@@ -0,0 +1,269 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HealthState describes how well a node responds to the host.
+type HealthState uint8
+
+const (
+	// Node has not been checked yet
+	HealthUnknown HealthState = iota
+	// Node responds to pings
+	HealthHealthy
+	// Node missed one or more pings
+	HealthDegraded
+	// Node missed too many pings
+	HealthUnresponsive
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case HealthUnknown:
+		return "unknown"
+	case HealthHealthy:
+		return "healthy"
+	case HealthDegraded:
+		return "degraded"
+	case HealthUnresponsive:
+		return "unresponsive"
+	default:
+		return fmt.Sprintf("HealthState(%d)", uint8(s))
+	}
+}
+
+const (
+	defaultHealthCheckInterval     = time.Second * 2
+	defaultDegradedAfterMisses     = 1
+	defaultUnresponsiveAfterMisses = 3
+)
+
+// HealthConfig controls the liveness monitoring of nodes.
+type HealthConfig struct {
+	// Interval between pings. Defaults to 2s.
+	Interval time.Duration
+	// Number of consecutive missed pings after which a node is degraded. Defaults to 1.
+	DegradedAfterMisses int
+	// Number of consecutive missed pings after which a node is unresponsive. Defaults to 3.
+	UnresponsiveAfterMisses int
+	// If set, no health monitoring is performed.
+	Disabled bool
+}
+
+// withDefaults returns a copy of the config with defaults applied.
+func (c HealthConfig) withDefaults() HealthConfig {
+	if c.Interval <= 0 {
+		c.Interval = defaultHealthCheckInterval
+	}
+	if c.DegradedAfterMisses <= 0 {
+		c.DegradedAfterMisses = defaultDegradedAfterMisses
+	}
+	if c.UnresponsiveAfterMisses <= 0 {
+		c.UnresponsiveAfterMisses = defaultUnresponsiveAfterMisses
+	}
+	if c.UnresponsiveAfterMisses <= c.DegradedAfterMisses {
+		c.UnresponsiveAfterMisses = c.DegradedAfterMisses + 1
+	}
+	return c
+}
+
+// HealthEvent is the payload of a health changed event.
+type HealthEvent struct {
+	Node     *Node
+	OldState HealthState
+	NewState HealthState
+}
+
+// Upper bounds of the latency histogram buckets.
+// The last bucket contains all higher latencies.
+var LatencyBucketBounds = []time.Duration{
+	time.Millisecond * 5,
+	time.Millisecond * 10,
+	time.Millisecond * 20,
+	time.Millisecond * 50,
+	time.Millisecond * 100,
+	time.Millisecond * 200,
+	time.Millisecond * 500,
+}
+
+// LatencyHistogram holds round trip times of pings to a node.
+type LatencyHistogram struct {
+	// Number of samples per bucket, see LatencyBucketBounds.
+	// Has len(LatencyBucketBounds)+1 entries.
+	Buckets []uint64
+	// Number of samples
+	Count uint64
+	// Sum of all samples
+	Total time.Duration
+	// Lowest & highest sample
+	Min, Max time.Duration
+	// Last sample
+	Last time.Duration
+}
+
+// Mean returns the average latency.
+func (lh LatencyHistogram) Mean() time.Duration {
+	if lh.Count == 0 {
+		return 0
+	}
+	return lh.Total / time.Duration(lh.Count)
+}
+
+// add a sample to the histogram.
+func (lh *LatencyHistogram) add(d time.Duration) {
+	if lh.Buckets == nil {
+		lh.Buckets = make([]uint64, len(LatencyBucketBounds)+1)
+	}
+	idx := len(LatencyBucketBounds)
+	for i, bound := range LatencyBucketBounds {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+	lh.Buckets[idx]++
+	if lh.Count == 0 || d < lh.Min {
+		lh.Min = d
+	}
+	if d > lh.Max {
+		lh.Max = d
+	}
+	lh.Count++
+	lh.Total += d
+	lh.Last = d
+}
+
+// clone returns a deep copy of the histogram.
+func (lh LatencyHistogram) clone() LatencyHistogram {
+	lh.Buckets = append([]uint64(nil), lh.Buckets...)
+	return lh
+}
+
+// nodeHealth holds the health state of a node.
+type nodeHealth struct {
+	mutex   sync.Mutex
+	state   HealthState
+	misses  int
+	latency LatencyHistogram
+}
+
+// Health returns the current health state of the node.
+func (n *Node) Health() HealthState {
+	n.health.mutex.Lock()
+	defer n.health.mutex.Unlock()
+	return n.health.state
+}
+
+// Latency returns a copy of the ping latency histogram of the node.
+func (n *Node) Latency() LatencyHistogram {
+	n.health.mutex.Lock()
+	defer n.health.mutex.Unlock()
+	return n.health.latency.clone()
+}
+
+// recordPing updates the health of the node with the result of a ping.
+func (n *Node) recordPing(cfg HealthConfig, latency time.Duration, err error) {
+	n.health.mutex.Lock()
+	old := n.health.state
+	if err == nil {
+		n.health.misses = 0
+		n.health.latency.add(latency)
+		n.health.state = HealthHealthy
+	} else {
+		n.health.misses++
+		if n.health.misses >= cfg.UnresponsiveAfterMisses {
+			n.health.state = HealthUnresponsive
+		} else if n.health.misses >= cfg.DegradedAfterMisses {
+			n.health.state = HealthDegraded
+		}
+	}
+	state := n.health.state
+	n.health.mutex.Unlock()
+
+	if old != state {
+		n.log.Info().
+			Str("old", old.String()).
+			Str("new", state.String()).
+			Msg("Node health changed")
+		n.host.invokeHealthChanged(HealthEvent{Node: n, OldState: old, NewState: state})
+	}
+}
+
+// Register a callback that gets invoked when the health state of a node changes
+func (h *host) RegisterHealthChanged(handler func(HealthEvent)) context.CancelFunc {
+	return h.healthEvent.Register(handler)
+}
+
+// Call all health changed handlers
+func (h *host) invokeHealthChanged(e HealthEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Msg("invokeHealthChanged")
+	h.markSnapshotDirty()
+	h.publishSnapshot()
+	h.healthEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindHealth, Node: e.Node, Health: &e})
+}
+
+// runHealthMonitor periodically pings all nodes until the given context is canceled.
+func (h *host) runHealthMonitor(ctx context.Context) {
+	cfg := h.Health.withDefaults()
+	if cfg.Disabled {
+		return
+	}
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			nodes, err := h.collectNodes(ctx)
+			if err != nil {
+				continue
+			}
+			for _, n := range nodes {
+				n := n
+				h.goRun(func() {
+					pingCtx, cancel := context.WithTimeout(ctx, cfg.Interval)
+					defer cancel()
+					latency, err := n.Ping(pingCtx)
+					if ctx.Err() != nil {
+						// Host is closing
+						return
+					}
+					n.recordPing(cfg, latency, err)
+				})
+			}
+		}
+	}
+}
+
+// collectNodes returns all nodes in the tree.
+// Returns an error when the given context is canceled before the message
+// queue collected the nodes.
+func (h *host) collectNodes(ctx context.Context) ([]*Node, error) {
+	result := make(chan []*Node, 1)
+	if err := h.postOnQueue(func() {
+		var nodes []*Node
+		var collect func(*Node)
+		collect = func(n *Node) {
+			nodes = append(nodes, n)
+			n.ForEachChild(collect)
+		}
+		collect(h.intfNode)
+		result <- nodes
+	}); err != nil {
+		return nil, err
+	}
+	select {
+	case nodes := <-result:
+		return nodes, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-h.queueCtx.Done():
+		return nil, ErrClosed
+	}
+}
@@ -0,0 +1,129 @@
+package host
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	tests := []struct {
+		latency      time.Duration
+		expectBucket int
+	}{
+		{latency: 0, expectBucket: 0},
+		{latency: time.Millisecond * 5, expectBucket: 0},
+		{latency: time.Millisecond*5 + 1, expectBucket: 1},
+		{latency: time.Millisecond * 15, expectBucket: 2},
+		{latency: time.Millisecond * 200, expectBucket: 5},
+		{latency: time.Millisecond * 500, expectBucket: 6},
+		{latency: time.Millisecond*500 + 1, expectBucket: 7},
+		{latency: time.Second * 10, expectBucket: 7},
+	}
+	for _, tc := range tests {
+		t.Run(tc.latency.String(), func(t *testing.T) {
+			var lh LatencyHistogram
+			lh.add(tc.latency)
+			expected := make([]uint64, len(LatencyBucketBounds)+1)
+			expected[tc.expectBucket] = 1
+			assert.Equal(t, expected, lh.Buckets)
+		})
+	}
+
+	var lh LatencyHistogram
+	assert.Equal(t, time.Duration(0), lh.Mean())
+	for _, d := range []time.Duration{time.Millisecond * 30, time.Millisecond * 10, time.Millisecond * 20} {
+		lh.add(d)
+	}
+	assert.Equal(t, uint64(3), lh.Count)
+	assert.Equal(t, time.Millisecond*10, lh.Min)
+	assert.Equal(t, time.Millisecond*30, lh.Max)
+	assert.Equal(t, time.Millisecond*20, lh.Last)
+	assert.Equal(t, time.Millisecond*20, lh.Mean())
+}
+
+func TestRecordPing(t *testing.T) {
+	errMissed := errors.New("no pong")
+	tests := []struct {
+		name string
+		cfg  HealthConfig
+		// Results of successive pings (nil means answered)
+		results      []error
+		expectStates []HealthState
+		// Expected state changes, as old, new pairs
+		expectEvents [][2]HealthState
+	}{
+		{
+			name:         "healthy",
+			results:      []error{nil, nil},
+			expectStates: []HealthState{HealthHealthy, HealthHealthy},
+			expectEvents: [][2]HealthState{{HealthUnknown, HealthHealthy}},
+		},
+		{
+			name:         "degraded, unresponsive & recovered",
+			results:      []error{nil, errMissed, errMissed, errMissed, errMissed, nil},
+			expectStates: []HealthState{HealthHealthy, HealthDegraded, HealthDegraded, HealthUnresponsive, HealthUnresponsive, HealthHealthy},
+			expectEvents: [][2]HealthState{
+				{HealthUnknown, HealthHealthy},
+				{HealthHealthy, HealthDegraded},
+				{HealthDegraded, HealthUnresponsive},
+				{HealthUnresponsive, HealthHealthy},
+			},
+		},
+		{
+			name:         "answer resets misses",
+			results:      []error{nil, errMissed, errMissed, nil, errMissed, errMissed},
+			expectStates: []HealthState{HealthHealthy, HealthDegraded, HealthDegraded, HealthHealthy, HealthDegraded, HealthDegraded},
+			expectEvents: [][2]HealthState{
+				{HealthUnknown, HealthHealthy},
+				{HealthHealthy, HealthDegraded},
+				{HealthDegraded, HealthHealthy},
+				{HealthHealthy, HealthDegraded},
+			},
+		},
+		{
+			name:         "custom thresholds",
+			cfg:          HealthConfig{DegradedAfterMisses: 2, UnresponsiveAfterMisses: 2},
+			results:      []error{nil, errMissed, errMissed, errMissed},
+			expectStates: []HealthState{HealthHealthy, HealthHealthy, HealthDegraded, HealthUnresponsive},
+			expectEvents: [][2]HealthState{
+				{HealthUnknown, HealthHealthy},
+				{HealthHealthy, HealthDegraded},
+				{HealthDegraded, HealthUnresponsive},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHost(t, &fakeConnection{})
+			n := h.intfNode
+			events := make(chan HealthEvent, len(tc.results))
+			h.RegisterHealthChanged(func(e HealthEvent) { events <- e })
+			cfg := tc.cfg.withDefaults()
+			var states []HealthState
+			for _, err := range tc.results {
+				n.recordPing(cfg, time.Millisecond, err)
+				states = append(states, n.Health())
+			}
+			assert.Equal(t, tc.expectStates, states)
+			var changes [][2]HealthState
+			for range tc.expectEvents {
+				e := receive(t, events)
+				assert.Same(t, n, e.Node)
+				changes = append(changes, [2]HealthState{e.OldState, e.NewState})
+			}
+			assert.Equal(t, tc.expectEvents, changes)
+			assert.Empty(t, events)
+			// Only answered pings are part of the latency histogram
+			answered := 0
+			for _, err := range tc.results {
+				if err == nil {
+					answered++
+				}
+			}
+			assert.Equal(t, uint64(answered), n.Latency().Count)
+		})
+	}
+}
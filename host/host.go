@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog"
 
@@ -21,21 +23,69 @@ type Host interface {
 	// Gets the node with the given address.
 	// Returns nil, false if not found
 	GetNode(addr bidib.Address) (*Node, bool)
+	// Returns an immutable snapshot of the node tree.
+	// Returns nil until the first node has been discovered.
+	Snapshot() *TreeSnapshot
 	// Register a callback that gets invoked on every node change
 	RegisterNodeChanged(func(NodeEvent)) context.CancelFunc
+	// Register a callback that gets invoked when a node is added to the node tree
+	RegisterNodeAdded(func(NodeAddedEvent)) context.CancelFunc
+	// Register a callback that gets invoked when a node is removed from the node tree
+	RegisterNodeRemoved(func(NodeRemovedEvent)) context.CancelFunc
 	// Register a callback that gets invoked on every reported dynamic state change
 	RegisterDynStateChanged(func(messages.BmDynState)) context.CancelFunc
 	// Register a callback that gets invoked on every reported BmAddress change
 	RegisterBmAddressChanged(func(messages.BmAddress)) context.CancelFunc
+	// Register a callback that gets invoked when the occupancy of a detector changes
+	RegisterOccupancyChanged(func(OccupancyEvent)) context.CancelFunc
+	// Register a callback that gets invoked when the state of an accessory changes
+	RegisterAccessoryChanged(func(AccessoryEvent)) context.CancelFunc
 	// Register a callback that gets invoked on every reported BstState change
 	RegisterBstStateChanged(func(messages.BstState)) context.CancelFunc
-	// Close the connections
+	// Register a callback that gets invoked when a decoder reports a CV value
+	RegisterPomResult(func(PomResultEvent)) context.CancelFunc
+	// Register a callback that gets invoked when the identify state of a node changes
+	RegisterIdentifyChanged(func(IdentifyEvent)) context.CancelFunc
+	// Register a callback that gets invoked when the health state of a node changes
+	RegisterHealthChanged(func(HealthEvent)) context.CancelFunc
+	// Register a callback that gets invoked when messages from a node are lost or duplicated
+	RegisterLinkQualityChanged(func(LinkQualityEvent)) context.CancelFunc
+	// Returns the lifecycle state of the host
+	State() HostState
+	// Wait until all nodes & features are known
+	WaitReady(ctx context.Context) error
+	// Register a callback that gets invoked when the lifecycle state of the host changes
+	RegisterStateChanged(func(StateEvent)) context.CancelFunc
+	// Subscribe returns a channel that receives all events accepted by the given filter.
+	// The subscription ends (and the channel is closed) when the given context is canceled.
+	// An EventKindResync event is delivered when events were dropped.
+	Subscribe(ctx context.Context, filter SubscriptionFilter) <-chan HostEvent
+	// Bring the layout in a safe state and stop the host
+	Shutdown(ctx context.Context, policy ShutdownPolicy) error
+	// Close the connections, leaving the layout & the interface as they are
 	Close() error
 }
 
 // Host config
 type Config struct {
 	Serial *serial.Config
+	// Time to wait for a response on a request before resending it.
+	// Defaults to 250ms.
+	RequestTimeout time.Duration
+	// Number of times a request is resend when no response is received.
+	// Defaults to 2, use a negative value to disable retries.
+	RequestRetries int
+	// Maximum number of messages held for a stalled node (and its children).
+	// Defaults to 256.
+	StallQueueLimit int
+	// Action taken when uplink messages from a node were lost
+	SequenceRecovery SequenceRecovery
+	// Liveness monitoring of nodes
+	Health HealthConfig
+	// If set, secure-ack is enabled with this repeat interval on occupancy
+	// detectors that support it, e.g. 200ms.
+	// Defaults to 0, which leaves secure-ack as configured on the node.
+	SecureAckInterval time.Duration
 }
 
 const (
@@ -69,17 +119,60 @@ type host struct {
 	intfNode         *Node
 	disabledState    int32
 	nodeChangedEvent Event[NodeEvent]
+	nodeAddedEvent   Event[NodeAddedEvent]
+	nodeRemovedEvent Event[NodeRemovedEvent]
 	messageQueue     chan HostMessage
 	cancelQueue      context.CancelFunc
 	closed           uint32
 	dynStateEvent    Event[messages.BmDynState]
 	bmAddressEvent   Event[messages.BmAddress]
+	occupancyEvent   Event[OccupancyEvent]
+	accessoryEvent   Event[AccessoryEvent]
 	bstStateEvent    Event[messages.BstState]
+	pomResultEvent   Event[PomResultEvent]
+	identifyEvent    Event[IdentifyEvent]
+	healthEvent      Event[HealthEvent]
+	linkQualityEvent Event[LinkQualityEvent]
+	allEvents        Event[HostEvent]
+	stateEvent       Event[StateEvent]
+	lifecycle        lifecycle
+	requests         pendingRequests
+	// Serializes sending messages & guards stall state of nodes
+	sendMutex sync.Mutex
+	// Last published snapshot of the node tree
+	snapshot      atomic.Pointer[TreeSnapshot]
+	snapshotDirty uint32
+	snapshotMutex sync.Mutex
+	// Context of the message queue & other host goroutines
+	queueCtx     context.Context
+	goroutines   sync.WaitGroup
+	shutdownOnce sync.Once
+	shutdownErr  error
 }
 
+// NodeEvent is the payload of a node changed event.
 type NodeEvent struct {
-	Node    *Node
-	Payload interface{}
+	Node *Node
+}
+
+// PomResultEvent is the payload of a program-on-main result event.
+// It is invoked when a decoder reports a CV value (via RailCom).
+type PomResultEvent struct {
+	// Node that received the report
+	Node *Node
+	// Address of the decoder
+	DccAddress uint32
+	// CV number (1..1024)
+	Cv uint32
+	// Value of the CV
+	Data uint8
+}
+
+// IdentifyEvent is the payload of an identify changed event.
+type IdentifyEvent struct {
+	Node *Node
+	// Set if the node is identifying itself
+	Identifying bool
 }
 
 const (
@@ -94,8 +187,8 @@ func (h *host) start() error {
 
 	// Prepare context & start message loop
 	ctx, cancel := context.WithCancel(context.Background())
-	h.cancelQueue = cancel
-	go h.runMessageQueue(ctx)
+	h.queueCtx, h.cancelQueue = ctx, cancel
+	h.goRun(func() { h.runMessageQueue(ctx) })
 
 	// Prepare transport connection
 	if sCfg := h.Serial; sCfg != nil {
@@ -114,6 +207,9 @@ func (h *host) start() error {
 	// Build interface node
 	h.intfNode = newNode(bidib.InterfaceAddress(), h, h.conn, log)
 
+	// Start monitoring node health
+	h.goRun(func() { h.runHealthMonitor(ctx) })
+
 	// Disable all communication
 	log.Debug().Msg("Disabling interface...")
 	if err := h.conn.SendMessages([]bidib.Message{messages.SysReset{}}, 0); err != nil {
@@ -131,20 +227,6 @@ func (h *host) start() error {
 	return nil
 }
 
-// Close any connections
-func (h *host) Close() error {
-	var err error
-	atomic.AddUint32(&h.closed, 1)
-	if conn := h.conn; conn != nil {
-		h.conn = nil
-		err = conn.Close()
-	}
-	if cancel := h.cancelQueue; cancel != nil {
-		cancel()
-	}
-	return err
-}
-
 // Has Close been called?
 func (h *host) IsClosed() bool {
 	return atomic.LoadUint32(&h.closed) != 0
@@ -158,6 +240,21 @@ func (h *host) GetRootNode() *Node {
 // Gets the node with the given address.
 // Returns nil, false if not found
 func (h *host) GetNode(addr bidib.Address) (*Node, bool) {
+	return h.findNode(addr, (*Node).visibleChildren)
+}
+
+// routeNode returns the node with the given address that messages for
+// that address are routed to.
+// Unlike GetNode, this includes children added during a node table reload.
+// Returns nil, false if not found
+func (h *host) routeNode(addr bidib.Address) (*Node, bool) {
+	return h.findNode(addr, (*Node).routingChildren)
+}
+
+// findNode walks the node tree to the node with the given address, using
+// the given function to select the children of a node.
+// Returns nil, false if not found
+func (h *host) findNode(addr bidib.Address, childrenOf func(*Node) []*Node) (*Node, bool) {
 	n := h.intfNode
 	for idx := 0; idx < 4; idx++ {
 		if addr[idx] == 0 {
@@ -166,7 +263,10 @@ func (h *host) GetNode(addr bidib.Address) (*Node, bool) {
 		}
 		// Go to child nodes
 		childFound := false
-		for _, child := range n.table.children {
+		n.mutex.RLock()
+		children := childrenOf(n)
+		n.mutex.RUnlock()
+		for _, child := range children {
 			if child != nil && child.Address.EqualsOrContains(addr) {
 				n = child
 				childFound = true
@@ -188,7 +288,34 @@ func (h *host) RegisterNodeChanged(handler func(NodeEvent)) context.CancelFunc {
 // Call all node changed handlers
 func (h *host) invokeNodeChanged(n NodeEvent) {
 	h.log.Debug().Str("addr", n.Node.Address.String()).Msg("invokeNodeChanged")
+	h.markSnapshotDirty()
 	h.nodeChangedEvent.Invoke(n)
+	h.publish(HostEvent{Kind: EventKindNodeChanged, Node: n.Node})
+}
+
+// Register a callback that gets invoked when a node is added to the node tree
+func (h *host) RegisterNodeAdded(handler func(NodeAddedEvent)) context.CancelFunc {
+	return h.nodeAddedEvent.Register(handler)
+}
+
+// Call all node added handlers
+func (h *host) invokeNodeAdded(e NodeAddedEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Msg("invokeNodeAdded")
+	h.nodeAddedEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindNodeAdded, Node: e.Node, NodeAdded: &e})
+}
+
+// Register a callback that gets invoked when a node is removed from the node tree
+func (h *host) RegisterNodeRemoved(handler func(NodeRemovedEvent)) context.CancelFunc {
+	return h.nodeRemovedEvent.Register(handler)
+}
+
+// Call all node removed handlers
+func (h *host) invokeNodeRemoved(e NodeRemovedEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Msg("invokeNodeRemoved")
+	h.markSnapshotDirty()
+	h.nodeRemovedEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindNodeRemoved, Node: e.Node, NodeRemoved: &e})
 }
 
 // Register a callback that gets invoked on every dynamic state change
@@ -200,6 +327,7 @@ func (h *host) RegisterDynStateChanged(handler func(messages.BmDynState)) contex
 func (h *host) invokeDynStateChanged(n messages.BmDynState) {
 	h.log.Debug().Str("addr", n.Address.String()).Msg("invokeDynStateChanged")
 	h.dynStateEvent.Invoke(n)
+	h.publish(HostEvent{Kind: EventKindDynState, Address: n.Address, MessageType: bidib.MSG_BM_DYN_STATE, Message: n})
 }
 
 // Register a callback that gets invoked on every BmAddress change
@@ -211,6 +339,7 @@ func (h *host) RegisterBmAddressChanged(handler func(messages.BmAddress)) contex
 func (h *host) invokeBmAdressChanged(n messages.BmAddress) {
 	h.log.Debug().Str("addr", n.Address.String()).Msg("invokeBmAdressChanged")
 	h.bmAddressEvent.Invoke(n)
+	h.publish(HostEvent{Kind: EventKindBmAddress, Address: n.Address, MessageType: bidib.MSG_BM_ADDRESS, Message: n})
 }
 
 // Register a callback that gets invoked on every BstState change
@@ -220,14 +349,45 @@ func (h *host) RegisterBstStateChanged(handler func(messages.BstState)) context.
 
 // Call all BstState changed handlers
 func (h *host) invokeBstStateChanged(n messages.BstState) {
-	h.log.Debug().Str("addr", n.Address.String()).Msg("invokeBmAdressChanged")
+	h.log.Debug().Str("addr", n.Address.String()).Msg("invokeBstStateChanged")
 	h.bstStateEvent.Invoke(n)
+	h.publish(HostEvent{Kind: EventKindBstState, Address: n.Address, MessageType: bidib.MSG_BOOST_STAT, Message: n})
+}
+
+// Register a callback that gets invoked when a decoder reports a CV value
+func (h *host) RegisterPomResult(handler func(PomResultEvent)) context.CancelFunc {
+	return h.pomResultEvent.Register(handler)
+}
+
+// Call all program-on-main result handlers
+func (h *host) invokePomResult(e PomResultEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Msg("invokePomResult")
+	h.pomResultEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindPomResult, Node: e.Node, PomResult: &e})
+}
+
+// Register a callback that gets invoked when the identify state of a node changes
+func (h *host) RegisterIdentifyChanged(handler func(IdentifyEvent)) context.CancelFunc {
+	return h.identifyEvent.Register(handler)
+}
+
+// Call all identify changed handlers
+func (h *host) invokeIdentifyChanged(n IdentifyEvent) {
+	h.log.Debug().Str("addr", n.Node.Address.String()).Bool("identifying", n.Identifying).Msg("invokeIdentifyChanged")
+	h.identifyEvent.Invoke(n)
+	h.publish(HostEvent{Kind: EventKindIdentify, Node: n.Node, Identify: &n})
+}
+
+// Returns true if spontaneous messages are enabled on the interface.
+func (h *host) isSpontaneousEnabled() bool {
+	return atomic.LoadInt32(&h.disabledState) == disabledStateEnabled
 }
 
 // Send a DISABLE message to the interface, blocking spontaneous messages.
 // Returns true if a DISABLE message was send, false is interface was already disabled.
 func (h *host) disableSpontaneousMessages() bool {
 	if atomic.SwapInt32(&h.disabledState, disabledStateDisabled) != disabledStateDisabled {
+		h.markLifecycleDirty()
 		h.intfNode.sendMessages(messages.SysDisable{})
 		return true
 	}
@@ -238,7 +398,10 @@ func (h *host) disableSpontaneousMessages() bool {
 // Returns true if a ENABLE message was send, false is interface was already enabled.
 func (h *host) enableSpontaneousMessages() bool {
 	if atomic.SwapInt32(&h.disabledState, disabledStateEnabled) != disabledStateEnabled {
+		h.markLifecycleDirty()
 		h.intfNode.sendMessages(messages.SysEnable{})
+		// Occupancy may have changed while disabled
+		h.resyncOccupancy()
 		return true
 	}
 	return false
@@ -0,0 +1,132 @@
+package host
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/binkynet/bidib"
+)
+
+// fakeConnection records all messages sent to it and optionally
+// lets a test respond to them.
+type fakeConnection struct {
+	mutex  sync.Mutex
+	sent   [][]bidib.Message
+	onSend func(attempt int, m []bidib.Message)
+}
+
+// SendMessages records the given messages and calls onSend (if set).
+func (c *fakeConnection) SendMessages(m []bidib.Message, seqNum bidib.SequenceNumber) error {
+	c.mutex.Lock()
+	c.sent = append(c.sent, m)
+	attempt := len(c.sent)
+	onSend := c.onSend
+	c.mutex.Unlock()
+	if onSend != nil {
+		onSend(attempt, m)
+	}
+	return nil
+}
+
+// Close the connection
+func (c *fakeConnection) Close() error {
+	return nil
+}
+
+// sendCount returns the number of SendMessages calls.
+func (c *fakeConnection) sendCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.sent)
+}
+
+// newTestHost builds a host with a running message queue that uses the
+// given connection.
+func newTestHost(t *testing.T, conn *fakeConnection) *host {
+	h := &host{
+		Config: Config{
+			RequestTimeout: time.Millisecond * 20,
+		},
+		log:          zerolog.Nop(),
+		conn:         conn,
+		messageQueue: make(chan HostMessage, messageQueueBufLen),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.queueCtx, h.cancelQueue = ctx, cancel
+	h.intfNode = newNode(bidib.InterfaceAddress(), h, conn, h.log)
+	h.goRun(func() { h.runMessageQueue(ctx) })
+	t.Cleanup(func() {
+		atomic.StoreUint32(&h.closed, 1)
+		cancel()
+		h.goroutines.Wait()
+	})
+	return h
+}
+
+// reply puts the given message on the message queue, as if it was
+// received from the node with given address.
+func (h *host) reply(addr bidib.Address, mType bidib.MessageType, m bidib.Message) {
+	h.enqueueMessage(uplinkMessage{Addr: addr, Type: mType, Message: m}, time.Second)
+}
+
+// syncQueue waits until all callbacks posted on the message queue so far have run.
+func (h *host) syncQueue(t *testing.T) {
+	done := make(chan struct{})
+	if err := h.postOnQueue(func() { close(done) }); err != nil {
+		t.Fatalf("failed to post on queue: %s", err)
+	}
+	<-done
+}
+
+// lastSent returns the last message sent to the connection.
+func (c *fakeConnection) lastSent() bidib.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.sent) == 0 {
+		return nil
+	}
+	last := c.sent[len(c.sent)-1]
+	return last[len(last)-1]
+}
+
+// replyNum puts the given message on the message queue, as if it was
+// received from the node with given address using given sequence number.
+func (h *host) replyNum(addr bidib.Address, num bidib.SequenceNumber, mType bidib.MessageType, m bidib.Message) {
+	h.enqueueMessage(uplinkMessage{Addr: addr, Type: mType, Message: m, Num: num}, time.Second)
+}
+
+// receiveEvents reads events from the given channel until last returns true
+// for an event. All events read before that event are returned.
+func receiveEvents(t *testing.T, ch <-chan HostEvent, last func(HostEvent) bool) []HostEvent {
+	var result []HostEvent
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("event channel closed")
+			}
+			if last(e) {
+				return result
+			}
+			result = append(result, e)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for events, got %d", len(result))
+		}
+	}
+}
+
+// sentMessages returns all messages sent to the connection.
+func (c *fakeConnection) sentMessages() []bidib.Message {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var result []bidib.Message
+	for _, m := range c.sent {
+		result = append(result, m...)
+	}
+	return result
+}
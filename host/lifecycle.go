@@ -0,0 +1,173 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/binkynet/bidib"
+)
+
+// HostState is the lifecycle state of the host.
+type HostState uint8
+
+const (
+	// Waiting for the interface to respond
+	HostStateConnecting HostState = iota
+	// Discovering nodes & their features
+	HostStateEnumerating
+	// All nodes & features are known
+	HostStateReady
+	// The node tree changed, rediscovering nodes
+	HostStateResyncing
+	// The host is closed
+	HostStateClosed
+)
+
+func (s HostState) String() string {
+	switch s {
+	case HostStateConnecting:
+		return "connecting"
+	case HostStateEnumerating:
+		return "enumerating"
+	case HostStateReady:
+		return "ready"
+	case HostStateResyncing:
+		return "resyncing"
+	case HostStateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("HostState(%d)", uint8(s))
+	}
+}
+
+// StateEvent is the payload of a host state changed event.
+type StateEvent struct {
+	OldState HostState
+	NewState HostState
+}
+
+// lifecycle holds the lifecycle state of the host.
+type lifecycle struct {
+	mutex sync.Mutex
+	state HostState
+	// Closed (and replaced) on every state change
+	changed chan struct{}
+	// Set when the node tree or the features of a node changed (accessed atomically)
+	dirty uint32
+}
+
+// State returns the current lifecycle state of the host.
+func (h *host) State() HostState {
+	h.lifecycle.mutex.Lock()
+	defer h.lifecycle.mutex.Unlock()
+	return h.lifecycle.state
+}
+
+// WaitReady waits until all nodes & features are known.
+// Returns ErrClosed when the host is closed before becoming ready.
+func (h *host) WaitReady(ctx context.Context) error {
+	for {
+		h.lifecycle.mutex.Lock()
+		state := h.lifecycle.state
+		if h.lifecycle.changed == nil {
+			h.lifecycle.changed = make(chan struct{})
+		}
+		changed := h.lifecycle.changed
+		h.lifecycle.mutex.Unlock()
+
+		switch state {
+		case HostStateReady:
+			return nil
+		case HostStateClosed:
+			return ErrClosed
+		}
+		select {
+		case <-changed:
+			// Check again
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// setState changes the lifecycle state of the host.
+func (h *host) setState(state HostState) {
+	h.lifecycle.mutex.Lock()
+	old := h.lifecycle.state
+	if old == state || old == HostStateClosed {
+		h.lifecycle.mutex.Unlock()
+		return
+	}
+	h.lifecycle.state = state
+	if h.lifecycle.changed != nil {
+		close(h.lifecycle.changed)
+		h.lifecycle.changed = nil
+	}
+	h.lifecycle.mutex.Unlock()
+
+	h.log.Info().
+		Str("old", old.String()).
+		Str("new", state.String()).
+		Msg("Host state changed")
+	e := StateEvent{OldState: old, NewState: state}
+	h.stateEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindHostState, Address: bidib.InterfaceAddress(), State: &e})
+}
+
+// Register a callback that gets invoked when the lifecycle state of the host changes
+func (h *host) RegisterStateChanged(handler func(StateEvent)) context.CancelFunc {
+	return h.stateEvent.Register(handler)
+}
+
+// markLifecycleDirty requests the lifecycle state to be derived again, because
+// a node table, the identity of a node or the loading state of its features changed.
+func (h *host) markLifecycleDirty() {
+	atomic.StoreUint32(&h.lifecycle.dirty, 1)
+}
+
+// updateLifecycle derives the lifecycle state from the state of the node tree,
+// if it changed since the last call.
+// This function is to be called by the message loop.
+func (h *host) updateLifecycle() {
+	if atomic.SwapUint32(&h.lifecycle.dirty, 0) == 0 {
+		return
+	}
+	complete := h.intfNode.isDiscoveredRecursive() && h.isSpontaneousEnabled()
+	switch h.State() {
+	case HostStateConnecting:
+		if h.intfNode.GetUniqueID() != (bidib.UniqueID{}) {
+			h.setState(HostStateEnumerating)
+		}
+	case HostStateEnumerating, HostStateResyncing:
+		if complete {
+			h.setState(HostStateReady)
+		}
+	case HostStateReady:
+		if !complete {
+			h.setState(HostStateResyncing)
+		}
+	}
+}
+
+// isDiscoveredRecursive returns true if the identity & features of this node
+// and all its (grand)children are known.
+// This function is to be called by the message loop.
+func (n *Node) isDiscoveredRecursive() bool {
+	if n.UniqueID == (bidib.UniqueID{}) || !n.hasCompleteNodeTable() {
+		return false
+	}
+	n.features.mutex.RLock()
+	loaded := n.features.loaded
+	n.features.mutex.RUnlock()
+	if !loaded {
+		return false
+	}
+	for _, child := range n.table.children {
+		if child != nil && !child.isDiscoveredRecursive() {
+			return false
+		}
+	}
+	return true
+}
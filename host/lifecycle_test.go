@@ -0,0 +1,36 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestUpdateLifecycle(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := h.intfNode
+	n.mutex.Lock()
+	n.UniqueID = bidib.UniqueID{0x40, 0, 13, 0x78, 0x56, 42, 0}
+	n.mutex.Unlock()
+
+	// Not recomputed without a change of the node tree
+	h.updateLifecycle()
+	assert.Equal(t, HostStateConnecting, h.State())
+	h.markLifecycleDirty()
+	h.updateLifecycle()
+	assert.Equal(t, HostStateEnumerating, h.State())
+
+	// Loading the features completes the discovery
+	n.processMessage(messages.FeatureCount{Count: 0})
+	h.enableSpontaneousMessages()
+	h.updateLifecycle()
+	assert.Equal(t, HostStateReady, h.State())
+
+	// Reloading the features starts a resync
+	n.processMessage(messages.FeatureCount{Count: 1})
+	h.updateLifecycle()
+	assert.Equal(t, HostStateResyncing, h.State())
+}
@@ -0,0 +1,211 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// SequenceRecovery defines the action taken when messages from a node were lost.
+type SequenceRecovery uint8
+
+const (
+	// Re-query the state of the node (default)
+	SequenceRecoveryRequery SequenceRecovery = iota
+	// Only report the loss
+	SequenceRecoveryNone
+)
+
+// LinkIssue identifies the kind of problem detected on the link with a node.
+type LinkIssue uint8
+
+const (
+	// One or more uplink messages were lost
+	LinkIssueGap LinkIssue = iota
+	// An uplink message was received twice
+	LinkIssueDuplicate
+	// The node reported a wrong downlink sequence number (BIDIB_ERR_SEQUENCE)
+	LinkIssueSequenceError
+)
+
+func (li LinkIssue) String() string {
+	switch li {
+	case LinkIssueGap:
+		return "gap"
+	case LinkIssueDuplicate:
+		return "duplicate"
+	case LinkIssueSequenceError:
+		return "sequence-error"
+	default:
+		return fmt.Sprintf("LinkIssue(%d)", uint8(li))
+	}
+}
+
+// LinkQuality holds statistics of the uplink messages received from a node.
+type LinkQuality struct {
+	// Number of messages received
+	Received uint64
+	// Number of times one or more messages were missing
+	Gaps uint64
+	// Total number of messages lost
+	Lost uint64
+	// Number of duplicate messages
+	Duplicates uint64
+	// Number of sequence errors reported by the node
+	SequenceErrors uint64
+}
+
+// LinkQualityEvent is the payload of a link quality event.
+type LinkQualityEvent struct {
+	Node  *Node
+	Issue LinkIssue
+	// Expected & received sequence number
+	Expected, Received bidib.SequenceNumber
+	// Statistics after the issue was detected
+	Quality LinkQuality
+}
+
+// nodeUplink holds the uplink sequence number tracking state of a node.
+type nodeUplink struct {
+	mutex    sync.Mutex
+	expected bidib.SequenceNumber
+	last     bidib.SequenceNumber
+	quality  LinkQuality
+}
+
+// LinkQuality returns the statistics of the uplink messages received from the node.
+func (n *Node) LinkQuality() LinkQuality {
+	n.uplink.mutex.Lock()
+	defer n.uplink.mutex.Unlock()
+	return n.uplink.quality
+}
+
+// sequenceDistance returns the number of sequence numbers between from (inclusive)
+// and to (exclusive), skipping 0.
+func sequenceDistance(from, to bidib.SequenceNumber) int {
+	if to >= from {
+		return int(to - from)
+	}
+	return int(to) + 255 - int(from)
+}
+
+// trackUplink checks the sequence number of a message received from the node.
+// Returns false if the message is a duplicate and must be ignored.
+// This function is to be called by the message loop.
+func (n *Node) trackUplink(num bidib.SequenceNumber) bool {
+	n.uplink.mutex.Lock()
+	u := &n.uplink
+	u.quality.Received++
+	if num == 0 {
+		// Unsequenced message, the node restarts its sequence
+		u.expected = 1
+		u.mutex.Unlock()
+		return true
+	}
+	expected := u.expected
+	var issue LinkIssue
+	switch {
+	case expected == 0 || num == expected:
+		// First message or in sequence
+		u.expected, u.last = num.Next(), num
+		u.mutex.Unlock()
+		return true
+	case num == u.last:
+		u.quality.Duplicates++
+		issue = LinkIssueDuplicate
+	default:
+		u.quality.Gaps++
+		u.quality.Lost += uint64(sequenceDistance(expected, num))
+		u.expected, u.last = num.Next(), num
+		issue = LinkIssueGap
+	}
+	quality := u.quality
+	u.mutex.Unlock()
+
+	n.log.Warn().
+		Str("issue", issue.String()).
+		Str("expected", expected.String()).
+		Str("received", num.String()).
+		Msg("Uplink sequence mismatch")
+	n.host.invokeLinkQualityChanged(LinkQualityEvent{
+		Node:     n,
+		Issue:    issue,
+		Expected: expected,
+		Received: num,
+		Quality:  quality,
+	})
+	if issue == LinkIssueDuplicate {
+		return false
+	}
+	n.recoverSequence()
+	return true
+}
+
+// processSequenceError handles a BIDIB_ERR_SEQUENCE error reported by the node.
+// This function is to be called by the message loop.
+func (n *Node) processSequenceError(m messages.SysError) {
+	var expected, received bidib.SequenceNumber
+	if len(m.Params) > 0 {
+		expected = bidib.SequenceNumber(m.Params[0]).Next()
+	}
+	if len(m.Params) > 1 {
+		received = bidib.SequenceNumber(m.Params[1])
+	}
+	n.uplink.mutex.Lock()
+	n.uplink.quality.SequenceErrors++
+	quality := n.uplink.quality
+	n.uplink.mutex.Unlock()
+
+	// Restart our downlink sequence
+	n.host.sendMutex.Lock()
+	n.nextSeqNum.Reset()
+	n.host.sendMutex.Unlock()
+
+	n.host.invokeLinkQualityChanged(LinkQualityEvent{
+		Node:     n,
+		Issue:    LinkIssueSequenceError,
+		Expected: expected,
+		Received: received,
+		Quality:  quality,
+	})
+	n.recoverSequence()
+}
+
+// recoverSequence performs the configured recovery after messages were lost.
+func (n *Node) recoverSequence() {
+	switch n.host.SequenceRecovery {
+	case SequenceRecoveryRequery:
+		n.requeryState()
+	}
+}
+
+// requeryState asks the node to report its current state again.
+func (n *Node) requeryState() {
+	if cs := n.extensions.cs; cs != nil {
+		cs.sendMessages(messages.CsSetState{
+			BaseMessage: cs.createBaseMessage(),
+			State:       bidib.BIDIB_CS_STATE_QUERY,
+		})
+	}
+	if bm := n.extensions.bm; bm != nil {
+		bm.resync()
+	}
+	if bst := n.extensions.bst; bst != nil {
+		bst.query()
+	}
+}
+
+// Register a callback that gets invoked when a link quality issue is detected
+func (h *host) RegisterLinkQualityChanged(handler func(LinkQualityEvent)) context.CancelFunc {
+	return h.linkQualityEvent.Register(handler)
+}
+
+// Call all link quality handlers
+func (h *host) invokeLinkQualityChanged(e LinkQualityEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Str("issue", e.Issue.String()).Msg("invokeLinkQualityChanged")
+	h.linkQualityEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindLinkQuality, Node: e.Node, LinkQuality: &e})
+}
@@ -0,0 +1,134 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestTrackUplink(t *testing.T) {
+	tests := []struct {
+		name string
+		// Sequence numbers of the received messages
+		nums []bidib.SequenceNumber
+		// Sequence numbers of the messages that are processed
+		expectProcessed []bidib.SequenceNumber
+		expectIssues    []LinkIssue
+		expectQuality   LinkQuality
+	}{
+		{
+			name:            "in order",
+			nums:            []bidib.SequenceNumber{1, 2, 3},
+			expectProcessed: []bidib.SequenceNumber{1, 2, 3},
+			expectQuality:   LinkQuality{Received: 3},
+		},
+		{
+			name:            "gap",
+			nums:            []bidib.SequenceNumber{1, 2, 5, 6},
+			expectProcessed: []bidib.SequenceNumber{1, 2, 5, 6},
+			expectIssues:    []LinkIssue{LinkIssueGap},
+			expectQuality:   LinkQuality{Received: 4, Gaps: 1, Lost: 2},
+		},
+		{
+			name:            "duplicate",
+			nums:            []bidib.SequenceNumber{1, 2, 2, 3},
+			expectProcessed: []bidib.SequenceNumber{1, 2, 3},
+			expectIssues:    []LinkIssue{LinkIssueDuplicate},
+			expectQuality:   LinkQuality{Received: 4, Duplicates: 1},
+		},
+		{
+			name:            "wrap 255 to 1",
+			nums:            []bidib.SequenceNumber{254, 255, 1, 2},
+			expectProcessed: []bidib.SequenceNumber{254, 255, 1, 2},
+			expectQuality:   LinkQuality{Received: 4},
+		},
+		{
+			name:            "gap over wrap",
+			nums:            []bidib.SequenceNumber{254, 2},
+			expectProcessed: []bidib.SequenceNumber{254, 2},
+			expectIssues:    []LinkIssue{LinkIssueGap},
+			expectQuality:   LinkQuality{Received: 2, Gaps: 1, Lost: 2},
+		},
+		{
+			name:            "reset to 0",
+			nums:            []bidib.SequenceNumber{1, 2, 3, 0, 1, 2},
+			expectProcessed: []bidib.SequenceNumber{1, 2, 3, 0, 1, 2},
+			expectQuality:   LinkQuality{Received: 6},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHost(t, &fakeConnection{})
+			n := h.intfNode
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := h.Subscribe(ctx, SubscriptionFilter{
+				Kinds:   []EventKind{EventKindUplinkMessage, EventKindLinkQuality},
+				Options: EventOptions{Overflow: OverflowUnbounded},
+			})
+
+			addr := bidib.InterfaceAddress()
+			for _, num := range tc.nums {
+				h.replyNum(addr, num, bidib.MSG_SYS_PONG, messages.SysPong{Value: uint8(num)})
+			}
+			// Unsequenced message marks the end of the test messages
+			h.replyNum(addr, 0, bidib.MSG_SYS_ERROR, messages.SysError{Error: bidib.BIDIB_ERR_NONE})
+
+			var processed []bidib.SequenceNumber
+			var issues []LinkIssue
+			for _, e := range receiveEvents(t, events, func(e HostEvent) bool {
+				_, last := e.Message.(messages.SysError)
+				return last
+			}) {
+				switch e.Kind {
+				case EventKindUplinkMessage:
+					processed = append(processed, bidib.SequenceNumber(e.Message.(messages.SysPong).Value))
+				case EventKindLinkQuality:
+					issues = append(issues, e.LinkQuality.Issue)
+				}
+			}
+			assert.Equal(t, tc.expectProcessed, processed)
+			assert.Equal(t, tc.expectIssues, issues)
+			expectQuality := tc.expectQuality
+			expectQuality.Received++ // End marker
+			assert.Equal(t, expectQuality, n.LinkQuality())
+		})
+	}
+}
+
+func TestProcessSequenceError(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := h.intfNode
+	h.sendMutex.Lock()
+	n.nextSeqNum = 7
+	h.sendMutex.Unlock()
+	var event LinkQualityEvent
+	received := make(chan struct{})
+	h.RegisterLinkQualityChanged(func(e LinkQualityEvent) {
+		event = e
+		close(received)
+	})
+
+	h.reply(bidib.InterfaceAddress(), bidib.MSG_SYS_ERROR, messages.SysError{
+		Error:  bidib.BIDIB_ERR_SEQUENCE,
+		Params: []byte{3, 6},
+	})
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("no link quality event")
+	}
+
+	h.sendMutex.Lock()
+	assert.Equal(t, bidib.SequenceNumber(0), n.nextSeqNum)
+	h.sendMutex.Unlock()
+	assert.Equal(t, LinkIssueSequenceError, event.Issue)
+	assert.Equal(t, bidib.SequenceNumber(4), event.Expected)
+	assert.Equal(t, bidib.SequenceNumber(6), event.Received)
+	assert.Equal(t, uint64(1), n.LinkQuality().SequenceErrors)
+}
@@ -1,7 +1,10 @@
 package host
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/binkynet/bidib"
@@ -11,6 +14,8 @@ import (
 )
 
 // Node represents a node in the bidib network.
+// The identity fields (UniqueID, FingerPrint, Magic) are written by the host's
+// message queue; read them using the getters or Host.Snapshot.
 type Node struct {
 	// Address of the node
 	Address bidib.Address
@@ -21,6 +26,13 @@ type Node struct {
 	// Magic of the node
 	Magic uint16
 
+	// Guards the identity fields, versions, table & extensions
+	mutex sync.RWMutex
+	// Versions reported by the node
+	versions struct {
+		protocol bidib.ProtocolVersion
+		software []messages.VersionTriple
+	}
 	// Host containing this node
 	host *host
 	// connection used to communicate with the node
@@ -37,16 +49,42 @@ type Node struct {
 		count uint8
 		// Child nodes
 		children []*Node
+		// Child nodes before the table was reloaded
+		previous []*Node
 		// Set when all child nodes are received
 		ready bool
 	}
 	features struct {
 		mutex sync.RWMutex
 		all   map[bidib.FeatureID]uint8
+		// Set while features are being fetched using FEATURE_GETNEXT
+		loading bool
+		// Set when all features have been fetched
+		loaded bool
+	}
+	// User strings (namespace 0)
+	strings struct {
+		mutex       sync.RWMutex
+		productName string
+		userName    string
 	}
+	// Liveness of the node
+	health nodeHealth
+	// Uplink sequence number tracking
+	uplink nodeUplink
+	// Flow control state
+	stall nodeStall
+	// Set if the node reported that it is identifying itself (accessed atomically)
+	identifying uint32
+	// Last value used in a ping message (accessed atomically)
+	lastPingValue uint32
+
 	extensions struct {
 		cs  *NodeCs
 		bst *NodeBst
+		bm  *NodeBm
+		acc *NodeAccessory
+		lc  *NodeLc
 	}
 }
 
@@ -62,13 +100,62 @@ func newNode(addr bidib.Address, host *host, conn transport.Connection, log zero
 
 // ForEachChild calls the given function for each (direct) child of this node.
 func (n *Node) ForEachChild(cb func(*Node)) {
-	for _, child := range n.table.children {
+	n.mutex.RLock()
+	children := n.visibleChildren()
+	n.mutex.RUnlock()
+	for _, child := range children {
 		if child != nil {
 			cb(child)
 		}
 	}
 }
 
+// GetUniqueID returns the unique ID of the node.
+func (n *Node) GetUniqueID() bidib.UniqueID {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.UniqueID
+}
+
+// GetFingerPrint returns the fingerprint of the node.
+func (n *Node) GetFingerPrint() uint32 {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.FingerPrint
+}
+
+// GetMagic returns the magic of the node.
+func (n *Node) GetMagic() uint16 {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.Magic
+}
+
+// ProtocolVersion returns the BiDiB protocol version reported by the node.
+// The result is unknown (zero) until the node has answered MSG_SYS_GET_P_VERSION.
+func (n *Node) ProtocolVersion() bidib.ProtocolVersion {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.versions.protocol
+}
+
+// SoftwareVersions returns the software versions reported by the node.
+// The first entry is the version of the node itself, additional entries
+// are versions of subsystems (like coprocessors).
+func (n *Node) SoftwareVersions() []messages.VersionTriple {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return append([]messages.VersionTriple(nil), n.versions.software...)
+}
+
+// SupportsBoostDiagnostic returns true if the protocol version of the node (0.10 and up)
+// includes MSG_BOOST_DIAGNOSTIC.
+// The booster extension uses it only to ignore the deprecated MSG_BOOST_CURRENT
+// of such nodes; both messages are accepted from all other nodes.
+func (n *Node) SupportsBoostDiagnostic() bool {
+	return n.ProtocolVersion().AtLeast(bidib.ProtocolVersionBoostDiagnostic)
+}
+
 // Gets the feature value with given id.
 // Returns value, found
 func (n *Node) GetFeature(feature bidib.FeatureID) (uint8, bool) {
@@ -78,6 +165,199 @@ func (n *Node) GetFeature(feature bidib.FeatureID) (uint8, bool) {
 	return result, ok
 }
 
+// hasAllFeatures returns true if all features have been fetched from the node.
+func (n *Node) hasAllFeatures() bool {
+	n.features.mutex.RLock()
+	defer n.features.mutex.RUnlock()
+	return n.features.loaded
+}
+
+// isLoadingFeatures returns true while the features are being fetched using FEATURE_GETNEXT.
+func (n *Node) isLoadingFeatures() bool {
+	n.features.mutex.RLock()
+	defer n.features.mutex.RUnlock()
+	return n.features.loading
+}
+
+// QueryFeature fetches the current value of the feature with given id from the node.
+// Returns ErrFeatureNotAvailable if the node does not support the feature.
+// Returns ErrFeaturesLoading while the features of the node are being fetched.
+func (n *Node) QueryFeature(ctx context.Context, feature bidib.FeatureID) (uint8, error) {
+	if n.isLoadingFeatures() {
+		// The response cannot be told apart from the next entry of the feature list
+		return 0, ErrFeaturesLoading
+	}
+	var value uint8
+	keys := []responseKey{
+		n.responseKey(bidib.MSG_FEATURE, uint32(feature)),
+		n.responseKey(bidib.MSG_FEATURE_NA, uint32(feature)),
+	}
+	err := n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		switch m := m.(type) {
+		case messages.Feature:
+			value = m.Value
+			return true, nil
+		case messages.FeatureNa:
+			return true, fmt.Errorf("%w: %s", ErrFeatureNotAvailable, feature)
+		}
+		return false, nil
+	}, messages.FeatureGet{BaseMessage: n.createBaseMessage(), Feature: feature})
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// SetFeature changes the value of the feature with given id on the node.
+// The node confirms the change with the value actually used, which is returned
+// and stored in the feature cache.
+// Returns ErrFeatureNotAvailable if the node does not support the feature.
+// Returns ErrFeaturesLoading while the features of the node are being fetched.
+func (n *Node) SetFeature(ctx context.Context, feature bidib.FeatureID, value uint8) (uint8, error) {
+	if n.isLoadingFeatures() {
+		// The confirmation cannot be told apart from the next entry of the feature list
+		return 0, ErrFeaturesLoading
+	}
+	if info, found := feature.Info(); found {
+		if err := info.Validate(value); err != nil {
+			return 0, fmt.Errorf("cannot set %s: %w", feature, err)
+		}
+	}
+	var confirmed uint8
+	keys := []responseKey{
+		n.responseKey(bidib.MSG_FEATURE, uint32(feature)),
+		n.responseKey(bidib.MSG_FEATURE_NA, uint32(feature)),
+	}
+	err := n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		switch m := m.(type) {
+		case messages.Feature:
+			confirmed = m.Value
+			return true, nil
+		case messages.FeatureNa:
+			return true, fmt.Errorf("%w: %s", ErrFeatureNotAvailable, feature)
+		}
+		return false, nil
+	}, messages.FeatureSet{BaseMessage: n.createBaseMessage(), Feature: feature, Value: value})
+	if err != nil {
+		return 0, err
+	}
+	if confirmed != value {
+		n.log.Info().
+			Str("feature", feature.String()).
+			Uint8("requested", value).
+			Uint8("confirmed", confirmed).
+			Msg("Node adjusted feature value")
+	}
+	n.invokeNodeChanged()
+	return confirmed, nil
+}
+
+const (
+	// String namespace for node strings
+	stringNamespaceNode = 0
+	// String IDs in the node namespace
+	stringIDProductName = 0
+	stringIDUserName    = 1
+)
+
+// ProductName returns the product name string reported by the node.
+// Empty if the node does not support strings.
+func (n *Node) ProductName() string {
+	n.strings.mutex.RLock()
+	defer n.strings.mutex.RUnlock()
+	return n.strings.productName
+}
+
+// UserName returns the user name string reported by the node.
+// Empty if the node does not support strings or no name is set.
+func (n *Node) UserName() string {
+	n.strings.mutex.RLock()
+	defer n.strings.mutex.RUnlock()
+	return n.strings.userName
+}
+
+// SetUserName writes the user name string of the node.
+// The length of the name is limited by FEATURE_STRING_SIZE.
+func (n *Node) SetUserName(ctx context.Context, name string) error {
+	size, _ := n.GetFeature(bidib.FEATURE_STRING_SIZE)
+	if size == 0 {
+		return fmt.Errorf("%w: %s", ErrFeatureNotAvailable, bidib.FEATURE_STRING_SIZE)
+	}
+	if len(name) > int(size) {
+		return fmt.Errorf("user name too long (%d > %d)", len(name), size)
+	}
+	keys := []responseKey{n.responseKey(bidib.MSG_STRING, stringKey(stringNamespaceNode, stringIDUserName))}
+	return n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		return true, nil
+	}, messages.StringSet{
+		BaseMessage: n.createBaseMessage(),
+		Namespace:   stringNamespaceNode,
+		StringID:    stringIDUserName,
+		Value:       name,
+	})
+}
+
+// readStrings requests the node strings if the node supports them.
+func (n *Node) readStrings() {
+	if size, _ := n.GetFeature(bidib.FEATURE_STRING_SIZE); size == 0 {
+		return
+	}
+	baseMsg := n.createBaseMessage()
+	n.sendMessages(messages.StringGet{
+		BaseMessage: baseMsg,
+		Namespace:   stringNamespaceNode,
+		StringID:    stringIDProductName,
+	}, messages.StringGet{
+		BaseMessage: baseMsg,
+		Namespace:   stringNamespaceNode,
+		StringID:    stringIDUserName,
+	})
+}
+
+// Ping sends a ping message to the node and waits for the pong.
+// Returns the round trip time.
+func (n *Node) Ping(ctx context.Context) (time.Duration, error) {
+	value := uint8(atomic.AddUint32(&n.lastPingValue, 1))
+	keys := []responseKey{n.responseKey(bidib.MSG_SYS_PONG, uint32(value))}
+	start := time.Now()
+	err := n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		return true, nil
+	}, messages.SysPing{BaseMessage: n.createBaseMessage(), Value: value})
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// RelevantPidBits returns the number of bits of the product ID (in the unique ID)
+// that identify the product, as reported by FEATURE_RELEVANT_PID_BITS.
+// If the node does not report this feature, bidib.DefaultRelevantPidBits is returned.
+func (n *Node) RelevantPidBits() uint8 {
+	if value, found := n.GetFeature(bidib.FEATURE_RELEVANT_PID_BITS); found && value > 0 {
+		return value
+	}
+	return bidib.DefaultRelevantPidBits
+}
+
+// Description returns a short human readable description of the node, e.g. "Fichtelbahn GBM16T #42".
+func (n *Node) Description() string {
+	return n.GetUniqueID().Describe(n.RelevantPidBits())
+}
+
+// Identify switches the identify indicator of the node on or off.
+// The node confirms the change, after which IsIdentifying is updated.
+func (n *Node) Identify(on bool) error {
+	return n.host.postOnQueue(func() {
+		n.sendMessages(messages.SysIdentify{BaseMessage: n.createBaseMessage(), Value: on})
+	})
+}
+
+// IsIdentifying returns true if the node reported that it is identifying itself.
+// This is the case after a call to Identify or when the identify button of the node was pressed.
+func (n *Node) IsIdentifying() bool {
+	return atomic.LoadUint32(&n.identifying) != 0
+}
+
 // Reset sends a reset message to the host.
 func (n *Node) Reset() {
 	n.host.GetRootNode().sendMessages(messages.SysReset{}, messages.SysGetUniqueID{})
@@ -86,15 +366,43 @@ func (n *Node) Reset() {
 // Gets the commandstation extension.
 // If this node does not have a DCC signal generator, the result is nil.
 func (n *Node) Cs() *NodeCs {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
 	return n.extensions.cs
 }
 
 // Gets the booster extension.
 // If this node does not have a booster, the result is nil.
 func (n *Node) Bst() *NodeBst {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
 	return n.extensions.bst
 }
 
+// Gets the occupancy detector extension.
+// If this node does not have occupancy detectors, the result is nil.
+func (n *Node) Bm() *NodeBm {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.extensions.bm
+}
+
+// Gets the accessory extension.
+// If this node does not have accessory control functions, the result is nil.
+func (n *Node) Accessory() *NodeAccessory {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.extensions.acc
+}
+
+// Gets the light/switch control extension.
+// If this node does not have switching functions, the result is nil.
+func (n *Node) Lc() *NodeLc {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	return n.extensions.lc
+}
+
 // Return a base message to include in all specific messages send to this node.
 func (n *Node) createBaseMessage() messages.BaseMessage {
 	return messages.BaseMessage{Address: n.Address}
@@ -105,76 +413,137 @@ func (n *Node) processMessage(m bidib.Message) error {
 	baseMsg := n.createBaseMessage()
 	switch m := m.(type) {
 	case messages.SysMagic:
+		n.mutex.Lock()
 		n.Magic = m.Magic
-		n.invokeNodeChanged(nil)
+		n.mutex.Unlock()
+		n.invokeNodeChanged()
+	case messages.SysPVersion:
+		n.mutex.Lock()
+		n.versions.protocol = bidib.ProtocolVersion{Major: m.Major, Minor: m.Minor}
+		n.mutex.Unlock()
+		n.log.Debug().Str("version", n.ProtocolVersion().String()).Msg("Got protocol version")
+		n.invokeNodeChanged()
+	case messages.SysSwVersion:
+		n.mutex.Lock()
+		n.versions.software = m.Versions
+		n.mutex.Unlock()
+		n.invokeNodeChanged()
 	case messages.SysUniqueID:
+		n.mutex.Lock()
 		n.UniqueID = m.UniqueID
 		n.FingerPrint = m.FingerPrint
+		n.mutex.Unlock()
+		n.host.markLifecycleDirty()
+		n.log.Debug().Str("node", n.Description()).Msg("Got unique ID")
 		// Set extensions for this node
 		n.setupExtensions()
 		// If node class indicates subnodes, trigger table discovery
 		if n.UniqueID.ClassID().HasSubNodes() {
 			n.sendMessages(messages.NodeTabGetAll{BaseMessage: baseMsg})
 		}
-		n.invokeNodeChanged(nil)
+		n.invokeNodeChanged()
 	case messages.NodeTabCount:
-		// Reset node table
-		n.table.count = m.TableLength
-		n.table.children = nil
-		n.table.ready = false
-		if m.TableLength == 0 {
-			// Table does not yet exist, try again in a bit
-			go func() {
-				time.Sleep(time.Millisecond * 20)
-				n.sendMessages(messages.NodeTabGetAll{BaseMessage: baseMsg})
-			}()
-		} else if !n.table.ready {
-			// Fetch next node table entry
-			n.sendMessages(messages.NodeTabGetNext{BaseMessage: baseMsg})
-		}
-		n.invokeNodeChanged(nil)
+		n.processNodeTabCount(m)
 	case messages.NodeTab:
-		n.table.version = m.TableVersion
-		if m.NodeAddress == 0 {
-			// Got my own node
-			n.table.children = append(n.table.children, nil)
-		} else {
-			// Found new child node
-			childAddr := n.Address.Append(m.NodeAddress)
-			child := newNode(childAddr, n.host, n.conn, n.log)
-			n.table.children = append(n.table.children, child)
-			if len(n.table.children) == int(n.table.count) {
-				n.table.ready = true
-			}
-			// Fetch basic info for child node
-			child.readNodeProperties()
-		}
-		// Fetch next node table entry (if any)
-		if !n.hasCompleteNodeTable() {
-			n.sendMessages(messages.NodeTabGetNext{BaseMessage: baseMsg})
-		}
-		n.invokeNodeChanged(nil)
+		n.processNodeTab(m)
 	case messages.NodeNew:
-		// Reset node table
-		n.table.count = 0
-		n.table.children = nil
-		n.table.ready = false
-		// Refetch node table
-		n.sendMessages(messages.NodeTabGetAll{BaseMessage: baseMsg})
-		n.invokeNodeChanged(nil)
+		n.processNodeNew(m)
+	case messages.NodeLost:
+		n.processNodeLost(m)
+	case messages.NodeNa:
+		n.processNodeNa(m)
 	case messages.FeatureCount:
 		n.features.mutex.Lock()
 		n.features.all = nil
+		n.features.loading = m.Count > 0
+		n.features.loaded = m.Count == 0
 		n.features.mutex.Unlock()
-		n.sendMessages(messages.FeatureGetNext{BaseMessage: baseMsg})
+		n.host.markLifecycleDirty()
+		if m.Count > 0 {
+			n.sendMessages(messages.FeatureGetNext{BaseMessage: baseMsg})
+		}
 	case messages.Feature:
 		n.features.mutex.Lock()
 		if n.features.all == nil {
 			n.features.all = make(map[bidib.FeatureID]uint8)
 		}
 		n.features.all[m.Feature] = m.Value
+		loading := n.features.loading
+		n.features.mutex.Unlock()
+		if m.Feature == bidib.FEATURE_RELEVANT_PID_BITS {
+			n.log.Debug().Str("node", n.Description()).Msg("Got relevant PID bits")
+		}
+		if loading {
+			// Fetch next feature
+			n.sendMessages(messages.FeatureGetNext{BaseMessage: baseMsg})
+		} else {
+			// Single feature changed (e.g. confirmation of FeatureSet)
+			n.invokeNodeChanged()
+		}
+	case messages.FeatureNa:
+		n.features.mutex.Lock()
+		delete(n.features.all, m.Feature)
 		n.features.mutex.Unlock()
-		n.sendMessages(messages.FeatureGetNext{BaseMessage: baseMsg})
+		if m.Feature == 255 {
+			// End of feature list
+			n.features.mutex.Lock()
+			n.features.loading = false
+			n.features.loaded = true
+			n.features.mutex.Unlock()
+			n.host.markLifecycleDirty()
+			n.readStrings()
+			if bm := n.extensions.bm; bm != nil {
+				bm.setupSecureAck()
+				if n.host.isSpontaneousEnabled() {
+					// Node joined after the interface was enabled
+					bm.resync()
+				}
+			}
+			if acc := n.extensions.acc; acc != nil {
+				acc.readAll()
+			}
+			if lc := n.extensions.lc; lc != nil {
+				lc.discover()
+			}
+			if bst := n.extensions.bst; bst != nil {
+				bst.query()
+			}
+			n.invokeNodeChanged()
+		}
+	case messages.Stall:
+		n.processStall(m)
+	case messages.BmDynState:
+		// Reported by occupancy detectors and command stations alike
+		n.host.invokeDynStateChanged(m)
+	case messages.BmAddress:
+		if bm := n.extensions.bm; bm != nil {
+			if err := bm.processMessage(m); err != nil {
+				return err
+			}
+		}
+		// Reported by occupancy detectors and command stations alike
+		n.host.invokeBmAdressChanged(m)
+	case messages.SysIdentityState:
+		value := uint32(0)
+		if m.Value {
+			value = 1
+		}
+		if atomic.SwapUint32(&n.identifying, value) != value {
+			n.host.invokeIdentifyChanged(IdentifyEvent{Node: n, Identifying: m.Value})
+			n.invokeNodeChanged()
+		}
+	case messages.String:
+		if m.Namespace == stringNamespaceNode {
+			n.strings.mutex.Lock()
+			switch m.StringID {
+			case stringIDProductName:
+				n.strings.productName = m.Value
+			case stringIDUserName:
+				n.strings.userName = m.Value
+			}
+			n.strings.mutex.Unlock()
+			n.invokeNodeChanged()
+		}
 	default:
 		if n.extensions.cs != nil {
 			if err := n.extensions.cs.processMessage(m); err != nil {
@@ -186,12 +555,41 @@ func (n *Node) processMessage(m bidib.Message) error {
 				return err
 			}
 		}
+		if n.extensions.bm != nil {
+			if err := n.extensions.bm.processMessage(m); err != nil {
+				return err
+			}
+		}
+		if n.extensions.acc != nil {
+			if err := n.extensions.acc.processMessage(m); err != nil {
+				return err
+			}
+		}
+		if n.extensions.lc != nil {
+			if err := n.extensions.lc.processMessage(m); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-// sendMessages sends the given messages to the node, updating the sequence number.
+// sendMessages sends the given messages to the node.
+// If the node (or one of its parents) is stalled, the messages are held
+// until the stall is cleared.
+// Failures are logged here, so callers that do not wait for a response
+// can ignore the returned error.
 func (n *Node) sendMessages(m ...bidib.Message) error {
+	err := n.host.sendOrHold(n, m)
+	if err != nil {
+		n.log.Warn().Err(err).Int("messages", len(m)).Msg("Failed to send messages")
+	}
+	return err
+}
+
+// transmit sends the given messages to the node, updating the sequence number.
+// The send mutex must be held by the caller.
+func (n *Node) transmit(m []bidib.Message) error {
 	seqNum := n.nextSeqNum
 	for i := 0; i < len(m); i++ {
 		n.nextSeqNum = n.nextSeqNum.Next()
@@ -203,6 +601,7 @@ func (n *Node) sendMessages(m ...bidib.Message) error {
 func (n *Node) readNodeProperties() error {
 	baseMsg := messages.BaseMessage{Address: n.Address}
 	return n.sendMessages(messages.SysGetMagic{BaseMessage: baseMsg},
+		messages.SysGetPVersion{BaseMessage: baseMsg},
 		messages.SysGetSwVersion{BaseMessage: baseMsg},
 		messages.SysGetUniqueID{BaseMessage: baseMsg},
 		messages.FeatureGetAll{BaseMessage: baseMsg},
@@ -237,19 +636,36 @@ func (n *Node) hasCompleteNodeTableRecursive() bool {
 
 // Set all extensions depending on class ID.
 func (n *Node) setupExtensions() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
 	if n.UniqueID.ClassID().HasDCCSignalGenerator() {
 		n.extensions.cs = &NodeCs{Node: n}
 	} else {
 		n.extensions.cs = nil
 	}
 	if n.UniqueID.ClassID().HasBoosterFunctions() {
-		n.extensions.bst = &NodeBst{Node: n}
+		n.extensions.bst = newNodeBst(n)
 	} else {
 		n.extensions.bst = nil
 	}
+	if n.UniqueID.ClassID().HasOccupancyDetectionFunctions() {
+		n.extensions.bm = newNodeBm(n)
+	} else {
+		n.extensions.bm = nil
+	}
+	if n.UniqueID.ClassID().HasAccessoryControlFunctions() {
+		n.extensions.acc = newNodeAccessory(n)
+	} else {
+		n.extensions.acc = nil
+	}
+	if n.UniqueID.ClassID().HasSwitchingFunctions() {
+		n.extensions.lc = newNodeLc(n)
+	} else {
+		n.extensions.lc = nil
+	}
 }
 
 // Call all node changed handlers for this node
-func (n *Node) invokeNodeChanged(payload interface{}) {
-	n.host.invokeNodeChanged(NodeEvent{Node: n, Payload: payload})
+func (n *Node) invokeNodeChanged() {
+	n.host.invokeNodeChanged(NodeEvent{Node: n})
 }
@@ -0,0 +1,406 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+var (
+	// Returned when an accessory operation is replaced by a newer operation on the same accessory.
+	ErrAccessorySuperseded = errors.New("accessory operation superseded")
+	// Returned when a node does not support a requested accessory parameter.
+	ErrParameterNotAvailable = errors.New("parameter not available")
+	// Returned when an accessory completed an operation with another aspect than requested.
+	ErrAspectMismatch = errors.New("accessory reached another aspect")
+)
+
+// AccessoryError is returned when an accessory reports an error.
+type AccessoryError struct {
+	// Number of the accessory
+	ANum uint8
+	// Error code (BIDIB_ACC_STATE_ERROR_*)
+	Code uint8
+	// Set if more errors are present
+	More bool
+}
+
+func (e AccessoryError) Error() string {
+	return fmt.Sprintf("accessory %d: %s", e.ANum, accessoryErrorName(e.Code))
+}
+
+// accessoryErrorName returns a readable name for the given accessory error code.
+func accessoryErrorName(code uint8) string {
+	switch code {
+	case bidib.BIDIB_ACC_STATE_ERROR_NONE:
+		return "no error"
+	case bidib.BIDIB_ACC_STATE_ERROR_VOID:
+		return "illegal aspect"
+	case bidib.BIDIB_ACC_STATE_ERROR_CURRENT:
+		return "current consumption too high"
+	case bidib.BIDIB_ACC_STATE_ERROR_LOWPOWER:
+		return "supply too low"
+	case bidib.BIDIB_ACC_STATE_ERROR_FUSE:
+		return "fuse blown"
+	case bidib.BIDIB_ACC_STATE_ERROR_TEMP:
+		return "temperature too high"
+	case bidib.BIDIB_ACC_STATE_ERROR_POSITION:
+		return "feedback error"
+	case bidib.BIDIB_ACC_STATE_ERROR_MAN_OP:
+		return "manually operated"
+	case bidib.BIDIB_ACC_STATE_ERROR_BULB:
+		return "bulb blown"
+	case bidib.BIDIB_ACC_STATE_ERROR_SERVO:
+		return "servo broken"
+	case bidib.BIDIB_ACC_STATE_ERROR_SELFTEST:
+		return "internal error"
+	default:
+		return fmt.Sprintf("error 0x%02x", code)
+	}
+}
+
+// Accessory is the last reported state of a single accessory.
+type Accessory struct {
+	// Number of the accessory
+	Number uint8
+	// Current (or targeted) aspect
+	Aspect uint8
+	// Number of aspects of the accessory
+	Total uint8
+	// Set while the accessory is moving to its aspect
+	InProgress bool
+	// Set if the node can verify the aspect of the accessory
+	HasFeedback bool
+	// Last reported error (nil if none)
+	Error *AccessoryError
+	// Time of the last reported state
+	Changed time.Time
+}
+
+// AccessoryEvent is the payload of an accessory changed event.
+type AccessoryEvent struct {
+	// Node that reported the change
+	Node *Node
+	// New state of the accessory
+	Accessory Accessory
+	// Set if the change was not caused by the host (MSG_ACCESSORY_NOTIFY)
+	Spontaneous bool
+}
+
+// AccessoryOperation tracks the execution of a SetAspect call.
+type AccessoryOperation struct {
+	// Number of the accessory
+	ANum uint8
+	// Requested aspect
+	Aspect uint8
+
+	mutex sync.Mutex
+	last  Accessory
+	done  chan struct{}
+	err   error
+}
+
+// newAccessoryOperation constructs a new operation that is not completed.
+func newAccessoryOperation(anum, aspect uint8) *AccessoryOperation {
+	return &AccessoryOperation{
+		ANum:   anum,
+		Aspect: aspect,
+		done:   make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed when the operation is complete.
+func (op *AccessoryOperation) Done() <-chan struct{} {
+	return op.done
+}
+
+// State returns the last reported state of the accessory.
+func (op *AccessoryOperation) State() Accessory {
+	op.mutex.Lock()
+	defer op.mutex.Unlock()
+	return op.last
+}
+
+// Wait until the accessory reached its aspect, reported an error,
+// or the given context expires.
+func (op *AccessoryOperation) Wait(ctx context.Context) (Accessory, error) {
+	select {
+	case <-op.done:
+		op.mutex.Lock()
+		defer op.mutex.Unlock()
+		return op.last, op.err
+	case <-ctx.Done():
+		return op.State(), ctx.Err()
+	}
+}
+
+// update records the given state, completing the operation when done or failed.
+// done is set when the node reported that the accessory finished its operation.
+// Returns true if the operation is complete.
+func (op *AccessoryOperation) update(a Accessory, done bool) bool {
+	op.mutex.Lock()
+	op.last = a
+	op.mutex.Unlock()
+	switch {
+	case a.Error != nil:
+		op.complete(*a.Error)
+	case !done:
+		return false
+	case a.Aspect != op.Aspect:
+		op.complete(fmt.Errorf("%w (anum=%d, aspect=%d, requested=%d)", ErrAspectMismatch, op.ANum, a.Aspect, op.Aspect))
+	default:
+		op.complete(nil)
+	}
+	return true
+}
+
+// complete marks the operation complete with the given error.
+func (op *AccessoryOperation) complete(err error) {
+	op.mutex.Lock()
+	defer op.mutex.Unlock()
+	select {
+	case <-op.done:
+		// Already completed
+	default:
+		op.err = err
+		close(op.done)
+	}
+}
+
+// NodeAccessory provides accessory control extension on the node.
+type NodeAccessory struct {
+	*Node
+	// Guards accessories & pending operations
+	stateMutex  sync.RWMutex
+	accessories []Accessory
+	pending     map[uint8]*AccessoryOperation
+}
+
+// newNodeAccessory constructs an accessory extension without known accessory state.
+func newNodeAccessory(n *Node) *NodeAccessory {
+	return &NodeAccessory{
+		Node:    n,
+		pending: make(map[uint8]*AccessoryOperation),
+	}
+}
+
+// Count returns the number of accessories of the node, as reported by FEATURE_ACCESSORY_COUNT.
+func (na *NodeAccessory) Count() uint8 {
+	count, _ := na.GetFeature(bidib.FEATURE_ACCESSORY_COUNT)
+	return count
+}
+
+// IsSurveilled returns true if the node reports accessories operated outside of BiDiB.
+func (na *NodeAccessory) IsSurveilled() bool {
+	value, _ := na.GetFeature(bidib.FEATURE_ACCESSORY_SURVEILLED)
+	return value != 0
+}
+
+// GetAccessory returns the last reported state of the accessory with given number.
+// Returns accessory, found
+func (na *NodeAccessory) GetAccessory(anum uint8) (Accessory, bool) {
+	na.stateMutex.RLock()
+	defer na.stateMutex.RUnlock()
+	if int(anum) >= len(na.accessories) {
+		return Accessory{}, false
+	}
+	return na.accessories[anum], true
+}
+
+// Accessories returns the last reported state of all accessories.
+func (na *NodeAccessory) Accessories() []Accessory {
+	na.stateMutex.RLock()
+	defer na.stateMutex.RUnlock()
+	return append([]Accessory(nil), na.accessories...)
+}
+
+// SetAspect sets the accessory with given number to the given aspect.
+// Returns once the node acknowledged the request; use the returned operation
+// to wait until the aspect is reached.
+func (na *NodeAccessory) SetAspect(ctx context.Context, anum, aspect uint8) (*AccessoryOperation, error) {
+	if count := na.Count(); anum >= count {
+		return nil, fmt.Errorf("accessory %d out of range (count %d)", anum, count)
+	}
+	op := newAccessoryOperation(anum, aspect)
+	na.stateMutex.Lock()
+	if prev := na.pending[anum]; prev != nil {
+		prev.complete(ErrAccessorySuperseded)
+	}
+	na.pending[anum] = op
+	na.stateMutex.Unlock()
+
+	keys := []responseKey{na.responseKey(bidib.MSG_ACCESSORY_STATE, uint32(anum))}
+	if err := na.host.request(ctx, na.Node, na.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		return true, nil
+	}, messages.AccessorySet{BaseMessage: na.createBaseMessage(), ANum: anum, Aspect: aspect}); err != nil {
+		na.removeOperation(op)
+		op.complete(err)
+		return nil, err
+	}
+	return op, nil
+}
+
+// GetParameter reads the parameter with given number of the accessory with given number.
+// Returns ErrParameterNotAvailable if the accessory does not support the parameter.
+func (na *NodeAccessory) GetParameter(ctx context.Context, anum, paraNum uint8) ([]byte, error) {
+	return na.parameterRequest(ctx, anum, paraNum, messages.AccessoryParaGet{
+		BaseMessage: na.createBaseMessage(),
+		ANum:        anum,
+		ParaNum:     paraNum,
+	})
+}
+
+// SetParameter writes the parameter with given number of the accessory with given number.
+// Returns the value confirmed by the node.
+// Returns ErrParameterNotAvailable if the accessory does not support the parameter.
+func (na *NodeAccessory) SetParameter(ctx context.Context, anum, paraNum uint8, data []byte) ([]byte, error) {
+	return na.parameterRequest(ctx, anum, paraNum, messages.AccessoryParaSet{
+		BaseMessage: na.createBaseMessage(),
+		ANum:        anum,
+		ParaNum:     paraNum,
+		Data:        data,
+	})
+}
+
+// GetSwitchTime reads the switch time of the accessory with given number.
+// Bit 7 selects the unit (0: 100ms, 1: 1s), bits 0-6 hold the value.
+func (na *NodeAccessory) GetSwitchTime(ctx context.Context, anum uint8) (uint8, error) {
+	data, err := na.GetParameter(ctx, anum, bidib.BIDIB_ACCESSORY_SWITCH_TIME)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < 1 {
+		return 0, fmt.Errorf("invalid switch time of accessory %d", anum)
+	}
+	return data[0], nil
+}
+
+// SetSwitchTime writes the switch time of the accessory with given number.
+// See GetSwitchTime for the coding.
+func (na *NodeAccessory) SetSwitchTime(ctx context.Context, anum, value uint8) error {
+	_, err := na.SetParameter(ctx, anum, bidib.BIDIB_ACCESSORY_SWITCH_TIME, []byte{value})
+	return err
+}
+
+// GetMacroMap reads the macro numbers that are mapped to the aspects
+// of the accessory with given number (one entry per aspect).
+func (na *NodeAccessory) GetMacroMap(ctx context.Context, anum uint8) ([]uint8, error) {
+	return na.GetParameter(ctx, anum, bidib.BIDIB_ACCESSORY_PARA_MACROMAP)
+}
+
+// SetMacroMap writes the macro numbers that are mapped to the aspects
+// of the accessory with given number (one entry per aspect).
+func (na *NodeAccessory) SetMacroMap(ctx context.Context, anum uint8, macros []uint8) error {
+	_, err := na.SetParameter(ctx, anum, bidib.BIDIB_ACCESSORY_PARA_MACROMAP, macros)
+	return err
+}
+
+// parameterRequest sends the given message and waits for the matching MSG_ACCESSORY_PARA.
+func (na *NodeAccessory) parameterRequest(ctx context.Context, anum, paraNum uint8, m bidib.Message) ([]byte, error) {
+	var result []byte
+	keys := []responseKey{na.responseKey(bidib.MSG_ACCESSORY_PARA, accessoryParaKey(anum, paraNum))}
+	err := na.host.request(ctx, na.Node, na.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if m, ok := m.(messages.AccessoryPara); ok {
+			if m.IsNotExist() {
+				return true, fmt.Errorf("%w: accessory %d parameter %d", ErrParameterNotAvailable, anum, paraNum)
+			}
+			result = m.Data
+			return true, nil
+		}
+		return false, nil
+	}, m)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// accessoryParaKey returns the response key for an accessory parameter message.
+func accessoryParaKey(anum, paraNum uint8) uint32 {
+	return uint32(anum)<<8 | uint32(paraNum)
+}
+
+// readAll requests the state of all accessories.
+// This function is to be called by the message loop.
+func (na *NodeAccessory) readAll() {
+	count := na.Count()
+	if count == 0 {
+		return
+	}
+	baseMsg := na.createBaseMessage()
+	msgs := make([]bidib.Message, 0, count)
+	for anum := uint8(0); anum < count; anum++ {
+		msgs = append(msgs, messages.AccessoryGet{BaseMessage: baseMsg, ANum: anum})
+	}
+	na.sendMessages(msgs...)
+}
+
+// removeOperation removes the given operation from the pending operations.
+func (na *NodeAccessory) removeOperation(op *AccessoryOperation) {
+	na.stateMutex.Lock()
+	defer na.stateMutex.Unlock()
+	if na.pending[op.ANum] == op {
+		delete(na.pending, op.ANum)
+	}
+}
+
+// updateAccessory records the given reported state, updating a pending operation (if any).
+func (na *NodeAccessory) updateAccessory(s messages.AccessoryStatus, spontaneous bool) {
+	a := Accessory{
+		Number:      s.ANum,
+		Aspect:      s.Aspect,
+		Total:       s.Total,
+		InProgress:  !s.IsError() && !s.IsDone(),
+		HasFeedback: s.HasFeedback(),
+		Changed:     time.Now(),
+	}
+	if s.IsError() {
+		// An error report with code NONE signals that the error was cleared
+		if code, more := s.ErrorCode(); code != bidib.BIDIB_ACC_STATE_ERROR_NONE {
+			a.Error = &AccessoryError{ANum: s.ANum, Code: code, More: more}
+		}
+	}
+	na.stateMutex.Lock()
+	for len(na.accessories) <= int(s.ANum) {
+		na.accessories = append(na.accessories, Accessory{Number: uint8(len(na.accessories))})
+	}
+	na.accessories[s.ANum] = a
+	op := na.pending[s.ANum]
+	if op != nil && op.update(a, s.IsDone()) {
+		delete(na.pending, s.ANum)
+	}
+	na.stateMutex.Unlock()
+	if a.Error != nil {
+		na.log.Warn().Err(a.Error).Msg("Accessory reported error")
+	}
+	na.host.invokeAccessoryChanged(AccessoryEvent{Node: na.Node, Accessory: a, Spontaneous: spontaneous})
+	na.invokeNodeChanged()
+}
+
+// process the message that is targeted for this node.
+func (na *NodeAccessory) processMessage(m bidib.Message) error {
+	switch m := m.(type) {
+	case messages.AccessoryState:
+		na.updateAccessory(m.AccessoryStatus, false)
+	case messages.AccessoryNotify:
+		na.updateAccessory(m.AccessoryStatus, true)
+	}
+	return nil
+}
+
+// Register a callback that gets invoked when the state of an accessory changes
+func (h *host) RegisterAccessoryChanged(handler func(AccessoryEvent)) context.CancelFunc {
+	return h.accessoryEvent.Register(handler)
+}
+
+// Call all accessory changed handlers
+func (h *host) invokeAccessoryChanged(e AccessoryEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Uint8("anum", e.Accessory.Number).Msg("invokeAccessoryChanged")
+	h.accessoryEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindAccessory, Node: e.Node, Accessory: &e})
+}
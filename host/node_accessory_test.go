@@ -0,0 +1,75 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestAccessoryOperationUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    messages.AccessoryStatus
+		complete  bool
+		expectErr error
+		hasError  bool
+	}{
+		{
+			name:   "moving",
+			status: messages.AccessoryStatus{Aspect: 1, Execute: bidib.BIDIB_ACC_STATE_WAIT, Wait: 5},
+		},
+		{
+			name:     "done",
+			status:   messages.AccessoryStatus{Aspect: 1, Execute: bidib.BIDIB_ACC_STATE_DONE},
+			complete: true,
+		},
+		{
+			name:      "done with other aspect",
+			status:    messages.AccessoryStatus{Aspect: 2, Execute: bidib.BIDIB_ACC_STATE_DONE},
+			complete:  true,
+			expectErr: ErrAspectMismatch,
+		},
+		{
+			name:      "error",
+			status:    messages.AccessoryStatus{Aspect: 1, Execute: bidib.BIDIB_ACC_STATE_ERROR, Wait: bidib.BIDIB_ACC_STATE_ERROR_FUSE},
+			complete:  true,
+			expectErr: AccessoryError{Code: bidib.BIDIB_ACC_STATE_ERROR_FUSE},
+			hasError:  true,
+		},
+		{
+			name:   "error cleared",
+			status: messages.AccessoryStatus{Aspect: 1, Execute: bidib.BIDIB_ACC_STATE_ERROR, Wait: bidib.BIDIB_ACC_STATE_ERROR_NONE},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHost(t, &fakeConnection{})
+			na := newNodeAccessory(h.intfNode)
+			op := newAccessoryOperation(0, 1)
+			na.pending[0] = op
+			na.updateAccessory(tc.status, false)
+
+			a, found := na.GetAccessory(0)
+			assert.True(t, found)
+			assert.Equal(t, tc.hasError, a.Error != nil)
+			select {
+			case <-op.Done():
+				assert.True(t, tc.complete, "operation must be pending")
+				_, err := op.Wait(context.Background())
+				if tc.expectErr != nil {
+					assert.ErrorIs(t, err, tc.expectErr)
+				} else {
+					assert.NoError(t, err)
+				}
+				assert.NotContains(t, na.pending, uint8(0))
+			default:
+				assert.False(t, tc.complete, "operation must be complete")
+				assert.Contains(t, na.pending, uint8(0))
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package host
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// BmDetector is the state of a single occupancy detector (section).
+type BmDetector struct {
+	// Local number of the detector
+	Number uint8
+	// Set if the section is occupied
+	Occupied bool
+	// DCC addresses detected in the section (including orientation bits)
+	DccAddresses []uint16
+	// Last current reported by the detector
+	Current bidib.Current
+	// Time of the last occupancy change
+	Changed time.Time
+	// Set if the detector keeps repeating its report, although it was mirrored
+	Repeating bool
+	// Number of successive reports without a change
+	repeats int
+}
+
+// BmConfidence is the confidence of the occupancy detection of a node.
+// A value of 0 means that the detection is reliable.
+type BmConfidence struct {
+	Void     uint8
+	Freeze   uint8
+	NoSignal uint8
+}
+
+// IsReliable returns true if the node reports no problems with the occupancy detection.
+func (c BmConfidence) IsReliable() bool {
+	return c == BmConfidence{}
+}
+
+// OccupancyEvent is the payload of an occupancy changed event.
+type OccupancyEvent struct {
+	// Node that reported the change
+	Node *Node
+	// Local number of the detector
+	Detector uint8
+	// Set if the section became occupied, false if it became free
+	Occupied bool
+	// Time the change was received by the host
+	Time time.Time
+	// Set if the node included a timestamp
+	HasNodeTimeStamp bool
+	// Timestamp reported by the node (unit: 1/16 ms, wrapping)
+	NodeTimeStamp uint16
+}
+
+const (
+	// Number of successive unchanged reports after which a detector is flagged as repeating
+	secureAckRepeatLimit = 3
+	// Time to wait for a node to confirm enabling secure-ack
+	secureAckSetupTimeout = time.Second * 2
+)
+
+// NodeBm provides occupancy detector extension on the node.
+type NodeBm struct {
+	*Node
+	// Guards detectors & confidence
+	stateMutex sync.RWMutex
+	detectors  []BmDetector
+	confidence BmConfidence
+}
+
+// newNodeBm constructs an occupancy detector extension without known detector state.
+func newNodeBm(n *Node) *NodeBm {
+	return &NodeBm{Node: n}
+}
+
+// Size returns the number of detectors of the node, as reported by FEATURE_BM_SIZE.
+func (nbm *NodeBm) Size() uint8 {
+	size, _ := nbm.GetFeature(bidib.FEATURE_BM_SIZE)
+	return size
+}
+
+// GetDetector returns the last reported state of the detector with given number.
+// Returns detector, found
+func (nbm *NodeBm) GetDetector(mnum uint8) (BmDetector, bool) {
+	nbm.stateMutex.RLock()
+	defer nbm.stateMutex.RUnlock()
+	if int(mnum) >= len(nbm.detectors) {
+		return BmDetector{}, false
+	}
+	return nbm.detectors[mnum].clone(), true
+}
+
+// Detectors returns the last reported state of all detectors.
+func (nbm *NodeBm) Detectors() []BmDetector {
+	nbm.stateMutex.RLock()
+	defer nbm.stateMutex.RUnlock()
+	result := make([]BmDetector, 0, len(nbm.detectors))
+	for _, d := range nbm.detectors {
+		result = append(result, d.clone())
+	}
+	return result
+}
+
+// GetConfidence returns the last reported confidence of the occupancy detection.
+func (nbm *NodeBm) GetConfidence() BmConfidence {
+	nbm.stateMutex.RLock()
+	defer nbm.stateMutex.RUnlock()
+	return nbm.confidence
+}
+
+// Resync asks the node to report the state of all detectors again.
+func (nbm *NodeBm) Resync() error {
+	return nbm.host.postOnQueue(func() {
+		nbm.resync()
+	})
+}
+
+// resync requests occupancy, addresses & confidence of all detectors.
+// This function is to be called by the message loop.
+func (nbm *NodeBm) resync() {
+	size := nbm.Size()
+	if size == 0 {
+		return
+	}
+	baseMsg := nbm.createBaseMessage()
+	// Range of MSG_BM_GET_RANGE must be a multiple of 8, use the same range for addresses
+	end := (int(size) + 7) / 8 * 8
+	if end > 128 {
+		end = 128
+	}
+	msgs := []bidib.Message{
+		messages.BmGetRange{BaseMessage: baseMsg, Start: 0, End: uint8(end)},
+	}
+	if on, _ := nbm.GetFeature(bidib.FEATURE_BM_ADDR_DETECT_ON); on != 0 {
+		msgs = append(msgs, messages.BmAddrGetRange{BaseMessage: baseMsg, Start: 0, End: uint8(end)})
+	}
+	msgs = append(msgs, messages.BmGetConfidence{BaseMessage: baseMsg})
+	nbm.sendMessages(msgs...)
+}
+
+// setupSecureAck enables secure-ack on the node, if configured in the host
+// and supported by the node.
+// Occupancy reports are mirrored once the node confirmed the change.
+// This function is to be called by the message loop.
+func (nbm *NodeBm) setupSecureAck() {
+	interval := nbm.host.SecureAckInterval
+	if interval <= 0 {
+		return
+	}
+	if available, _ := nbm.GetFeature(bidib.FEATURE_BM_SECACK_AVAILABLE); available == 0 {
+		return
+	}
+	// Feature unit is 10ms
+	value := interval / (time.Millisecond * 10)
+	if value < 1 {
+		value = 1
+	} else if value > 255 {
+		value = 255
+	}
+	if current, _ := nbm.GetFeature(bidib.FEATURE_BM_SECACK_ON); current == uint8(value) {
+		return
+	}
+	h := nbm.host
+	h.goRun(func() {
+		ctx, cancel := context.WithTimeout(h.queueCtx, secureAckSetupTimeout)
+		defer cancel()
+		if _, err := nbm.SetFeature(ctx, bidib.FEATURE_BM_SECACK_ON, uint8(value)); err != nil && h.queueCtx.Err() == nil {
+			nbm.log.Warn().Err(err).Msg("Failed to enable secure-ack")
+		}
+	})
+}
+
+// isSecureAckOn returns true if the node expects occupancy reports to be mirrored.
+func (nbm *NodeBm) isSecureAckOn() bool {
+	on, _ := nbm.GetFeature(bidib.FEATURE_BM_SECACK_ON)
+	return on != 0
+}
+
+// countReport records a (possibly repeated) report of a detector.
+// A detector that reports the same state again after being mirrored is flagged as repeating.
+// The state mutex must be held by the caller.
+// Returns true if the detector became flagged.
+func (nbm *NodeBm) countReport(d *BmDetector, changed bool) bool {
+	if changed {
+		d.repeats = 0
+		d.Repeating = false
+		return false
+	}
+	d.repeats++
+	if d.repeats >= secureAckRepeatLimit && !d.Repeating {
+		d.Repeating = true
+		return true
+	}
+	return false
+}
+
+// flagRepeating logs & reports that the detector with given number keeps repeating.
+func (nbm *NodeBm) flagRepeating(mnum uint8, reason string) {
+	nbm.log.Warn().
+		Uint8("mnum", mnum).
+		Str("reason", reason).
+		Msg("Occupancy detector keeps repeating its report")
+	nbm.invokeNodeChanged()
+}
+
+// detector returns the detector with given number, growing the list of detectors if needed.
+// The state mutex must be held by the caller.
+func (nbm *NodeBm) detector(mnum uint8) *BmDetector {
+	for len(nbm.detectors) <= int(mnum) {
+		nbm.detectors = append(nbm.detectors, BmDetector{
+			Number:  uint8(len(nbm.detectors)),
+			Current: bidib.CurrentUnknown,
+		})
+	}
+	return &nbm.detectors[mnum]
+}
+
+// setOccupied updates the occupancy of the detector with given number,
+// invoking the occupancy changed handlers if the state changed.
+// If countRepeats is set, unchanged reports are counted to detect repeating detectors.
+func (nbm *NodeBm) setOccupied(mnum uint8, occupied bool, countRepeats bool, e OccupancyEvent) {
+	now := time.Now()
+	nbm.stateMutex.Lock()
+	d := nbm.detector(mnum)
+	changed := compareAndAssign(&d.Occupied, occupied)
+	if changed {
+		d.Changed = now
+		if !occupied {
+			// Addresses are no longer valid once the section is free
+			d.DccAddresses = nil
+		}
+	}
+	repeating := countRepeats && nbm.countReport(d, changed)
+	nbm.stateMutex.Unlock()
+	if repeating {
+		nbm.flagRepeating(mnum, "unchanged report")
+	}
+	if changed {
+		e.Node = nbm.Node
+		e.Detector = mnum
+		e.Occupied = occupied
+		e.Time = now
+		nbm.host.invokeOccupancyChanged(e)
+		nbm.invokeNodeChanged()
+	}
+}
+
+// process the message that is targeted for this node.
+func (nbm *NodeBm) processMessage(m bidib.Message) error {
+	switch m := m.(type) {
+	case messages.BmOcc:
+		secAck := nbm.isSecureAckOn()
+		if secAck {
+			nbm.sendMessages(messages.BmMirrorOcc{BaseMessage: nbm.createBaseMessage(), MNum: m.MNum})
+		}
+		nbm.setOccupied(m.MNum, true, secAck, OccupancyEvent{
+			HasNodeTimeStamp: m.HasTimeStamp,
+			NodeTimeStamp:    m.TimeStamp,
+		})
+	case messages.BmFree:
+		secAck := nbm.isSecureAckOn()
+		if secAck {
+			nbm.sendMessages(messages.BmMirrorFree{BaseMessage: nbm.createBaseMessage(), MNum: m.MNum})
+		}
+		nbm.setOccupied(m.MNum, false, secAck, OccupancyEvent{})
+	case messages.BmMultiple:
+		secAck := nbm.isSecureAckOn()
+		if secAck {
+			nbm.sendMessages(messages.BmMirrorMultiple{
+				BaseMessage: nbm.createBaseMessage(),
+				Base:        m.Base,
+				Size:        m.Size,
+				Data:        m.Data,
+			})
+		}
+		for i := 0; i < int(m.Size); i++ {
+			mnum := int(m.Base) + i
+			if mnum > 255 {
+				break
+			}
+			nbm.setOccupied(uint8(mnum), m.IsOccupied(uint8(i)), secAck, OccupancyEvent{})
+		}
+	case messages.BmAddress:
+		var addrs []uint16
+		for _, addr := range m.DccAddresses {
+			// Address 0 means that no address is detected
+			if addr&0x3FFF != 0 {
+				addrs = append(addrs, addr)
+			}
+		}
+		nbm.stateMutex.Lock()
+		nbm.detector(m.MNum).DccAddresses = addrs
+		nbm.stateMutex.Unlock()
+		nbm.invokeNodeChanged()
+	case messages.BmCurrent:
+		nbm.stateMutex.Lock()
+		changed := compareAndAssign(&nbm.detector(m.MNum).Current, m.Current)
+		nbm.stateMutex.Unlock()
+		if changed {
+			nbm.invokeNodeChanged()
+		}
+	case messages.BmConfidence:
+		nbm.stateMutex.Lock()
+		changed := compareAndAssign(&nbm.confidence, BmConfidence{Void: m.Void, Freeze: m.Freeze, NoSignal: m.NoSignal})
+		nbm.stateMutex.Unlock()
+		if changed {
+			nbm.invokeNodeChanged()
+		}
+	case messages.SysError:
+		if m.Error == bidib.BIDIB_ERR_NO_SECACK_BY_HOST && len(m.Params) > 0 {
+			mnum := m.Params[0]
+			nbm.stateMutex.Lock()
+			d := nbm.detector(mnum)
+			flagged := !d.Repeating
+			d.Repeating = true
+			nbm.stateMutex.Unlock()
+			if flagged {
+				nbm.flagRepeating(mnum, "no secure-ack by host")
+			}
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of the detector that does not share memory with the original.
+func (d BmDetector) clone() BmDetector {
+	d.DccAddresses = append([]uint16(nil), d.DccAddresses...)
+	return d
+}
+
+// Register a callback that gets invoked when the occupancy of a detector changes
+func (h *host) RegisterOccupancyChanged(handler func(OccupancyEvent)) context.CancelFunc {
+	return h.occupancyEvent.Register(handler)
+}
+
+// Call all occupancy changed handlers
+func (h *host) invokeOccupancyChanged(e OccupancyEvent) {
+	h.log.Debug().Str("addr", e.Node.Address.String()).Uint8("mnum", e.Detector).Bool("occupied", e.Occupied).Msg("invokeOccupancyChanged")
+	h.occupancyEvent.Invoke(e)
+	h.publish(HostEvent{Kind: EventKindOccupancy, Node: e.Node, Occupancy: &e})
+}
+
+// resyncOccupancy requests the occupancy state of all occupancy detector nodes
+// for which the features are known.
+// This function is to be called by the message loop.
+func (h *host) resyncOccupancy() {
+	h.intfNode.forEachNodeRecursive(func(n *Node) {
+		if bm := n.Bm(); bm != nil && n.hasAllFeatures() {
+			bm.resync()
+		}
+	})
+}
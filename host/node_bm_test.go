@@ -0,0 +1,220 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestSetupSecureAck(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  time.Duration
+		available uint8
+		expectOn  uint8
+	}{
+		{name: "disabled by default", available: 1},
+		{name: "not available", interval: time.Millisecond * 200},
+		{name: "enabled", interval: time.Millisecond * 200, available: 1, expectOn: 20},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnection{}
+			h := newTestHost(t, conn)
+			h.SecureAckInterval = tc.interval
+			nbm := newNodeBm(h.intfNode)
+			nbm.features.all = map[bidib.FeatureID]uint8{bidib.FEATURE_BM_SECACK_AVAILABLE: tc.available}
+			confirmed := make(chan struct{})
+			conn.onSend = func(attempt int, m []bidib.Message) {
+				if fs, ok := m[0].(messages.FeatureSet); ok {
+					h.reply(nbm.Address, bidib.MSG_FEATURE, messages.Feature{Feature: fs.Feature, Value: fs.Value})
+					close(confirmed)
+				}
+			}
+			h.postOnQueue(nbm.setupSecureAck)
+			if tc.expectOn == 0 {
+				h.syncQueue(t)
+				assert.Equal(t, 0, conn.sendCount())
+				assert.False(t, nbm.isSecureAckOn())
+				return
+			}
+			<-confirmed
+			assert.Eventually(t, nbm.isSecureAckOn, time.Second, time.Millisecond)
+			on, _ := nbm.GetFeature(bidib.FEATURE_BM_SECACK_ON)
+			assert.Equal(t, tc.expectOn, on)
+		})
+	}
+}
+
+// setupTestNodeClass gives the interface node of the host the given class
+// and features.
+func setupTestNodeClass(h *host, class bidib.ClassID, features map[bidib.FeatureID]uint8) *Node {
+	n := h.intfNode
+	n.mutex.Lock()
+	n.UniqueID = bidib.UniqueID{uint8(class), 0, 13, 0x78, 0x56, 42, 0}
+	n.mutex.Unlock()
+	n.setupExtensions()
+	n.features.mutex.Lock()
+	n.features.all = features
+	n.features.loaded = true
+	n.features.mutex.Unlock()
+	return n
+}
+
+// receive returns the next value from the given channel.
+func receive[T any](t *testing.T, ch <-chan T) T {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for value")
+		var zero T
+		return zero
+	}
+}
+
+const (
+	classBm = bidib.ClassID(1 << 6)
+	classCs = bidib.ClassID(1 << 4)
+)
+
+func TestBmDetectorState(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := setupTestNodeClass(h, classBm, nil)
+	occupancy := make(chan OccupancyEvent, 8)
+	h.RegisterOccupancyChanged(func(e OccupancyEvent) { occupancy <- e })
+	addrs := make(chan messages.BmAddress, 8)
+	h.RegisterBmAddressChanged(func(m messages.BmAddress) { addrs <- m })
+
+	h.reply(n.Address, bidib.MSG_BM_OCC, messages.BmOcc{MNum: 2, HasTimeStamp: true, TimeStamp: 100})
+	e := receive(t, occupancy)
+	assert.Equal(t, uint8(2), e.Detector)
+	assert.True(t, e.Occupied)
+	assert.True(t, e.HasNodeTimeStamp)
+	assert.Equal(t, uint16(100), e.NodeTimeStamp)
+
+	h.reply(n.Address, bidib.MSG_BM_ADDRESS, messages.BmAddress{MNum: 2, DccAddresses: []uint16{0x8003, 0}})
+	assert.Equal(t, uint8(2), receive(t, addrs).MNum)
+	d, found := n.Bm().GetDetector(2)
+	require.True(t, found)
+	assert.True(t, d.Occupied)
+	assert.Equal(t, []uint16{0x8003}, d.DccAddresses)
+	_, found = n.Bm().GetDetector(3)
+	assert.False(t, found)
+
+	// Unchanged report is not an occupancy change
+	h.reply(n.Address, bidib.MSG_BM_OCC, messages.BmOcc{MNum: 2})
+	h.reply(n.Address, bidib.MSG_BM_FREE, messages.BmFree{MNum: 2})
+	e = receive(t, occupancy)
+	assert.Equal(t, uint8(2), e.Detector)
+	assert.False(t, e.Occupied)
+	d, _ = n.Bm().GetDetector(2)
+	assert.False(t, d.Occupied)
+	assert.Empty(t, d.DccAddresses)
+	assert.Empty(t, occupancy)
+}
+
+func TestBmConfidence(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := setupTestNodeClass(h, classBm, nil)
+	assert.True(t, n.Bm().GetConfidence().IsReliable())
+
+	h.reply(n.Address, bidib.MSG_BM_CONFIDENCE, messages.BmConfidence{Void: 1, NoSignal: 2})
+	h.syncQueue(t)
+	c := n.Bm().GetConfidence()
+	assert.False(t, c.IsReliable())
+	assert.Equal(t, BmConfidence{Void: 1, NoSignal: 2}, c)
+}
+
+func TestBmResync(t *testing.T) {
+	tests := []struct {
+		name     string
+		features map[bidib.FeatureID]uint8
+		expect   []bidib.Message
+	}{
+		{
+			name: "no detectors",
+		},
+		{
+			name:     "occupancy only",
+			features: map[bidib.FeatureID]uint8{bidib.FEATURE_BM_SIZE: 16},
+			expect: []bidib.Message{
+				messages.BmGetRange{Start: 0, End: 16},
+				messages.BmGetConfidence{},
+			},
+		},
+		{
+			name: "with addresses",
+			features: map[bidib.FeatureID]uint8{
+				bidib.FEATURE_BM_SIZE:           20,
+				bidib.FEATURE_BM_ADDR_DETECT_ON: 1,
+			},
+			expect: []bidib.Message{
+				messages.BmGetRange{Start: 0, End: 24},
+				messages.BmAddrGetRange{Start: 0, End: 24},
+				messages.BmGetConfidence{},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnection{}
+			h := newTestHost(t, conn)
+			n := setupTestNodeClass(h, classBm, tc.features)
+			require.NoError(t, n.Bm().Resync())
+			h.syncQueue(t)
+			assert.Equal(t, tc.expect, conn.sentMessages())
+		})
+	}
+}
+
+func TestBmEventsFromCs(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := setupTestNodeClass(h, classCs, nil)
+	require.Nil(t, n.Bm())
+	dynStates := make(chan messages.BmDynState, 1)
+	h.RegisterDynStateChanged(func(m messages.BmDynState) { dynStates <- m })
+	addrs := make(chan messages.BmAddress, 1)
+	h.RegisterBmAddressChanged(func(m messages.BmAddress) { addrs <- m })
+
+	h.reply(n.Address, bidib.MSG_BM_DYN_STATE, messages.BmDynState{MNum: 1, DccAddress: 3, DynNum: 1, Value: 50})
+	h.reply(n.Address, bidib.MSG_BM_ADDRESS, messages.BmAddress{MNum: 1, DccAddresses: []uint16{3}})
+	assert.Equal(t, uint8(50), receive(t, dynStates).Value)
+	assert.Equal(t, []uint16{3}, receive(t, addrs).DccAddresses)
+}
+
+func TestBmRepeatingReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		report bidib.Message
+		mType  bidib.MessageType
+	}{
+		{name: "occupied", mType: bidib.MSG_BM_OCC, report: messages.BmOcc{MNum: 0}},
+		{name: "free", mType: bidib.MSG_BM_FREE, report: messages.BmFree{MNum: 0}},
+		{name: "multiple", mType: bidib.MSG_BM_MULTIPLE, report: messages.BmMultiple{Base: 0, Size: 8, Data: []byte{0x01}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnection{}
+			h := newTestHost(t, conn)
+			n := setupTestNodeClass(h, classBm, map[bidib.FeatureID]uint8{bidib.FEATURE_BM_SECACK_ON: 20})
+			h.reply(n.Address, tc.mType, tc.report)
+			h.syncQueue(t)
+			d, _ := n.Bm().GetDetector(0)
+			assert.False(t, d.Repeating)
+			for i := 0; i < secureAckRepeatLimit; i++ {
+				h.reply(n.Address, tc.mType, tc.report)
+			}
+			h.syncQueue(t)
+			d, _ = n.Bm().GetDetector(0)
+			assert.True(t, d.Repeating)
+			// Every report is mirrored
+			assert.Equal(t, secureAckRepeatLimit+1, conn.sendCount())
+		})
+	}
+}
@@ -1,73 +1,297 @@
 package host
 
 import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/binkynet/bidib"
 	"github.com/binkynet/bidib/messages"
 )
 
+const (
+	// Maximum number of state transitions kept in the booster history
+	bstHistoryLimit = 64
+)
+
+// BstTransition is a change of the booster state.
+type BstTransition struct {
+	From bidib.BstState
+	To   bidib.BstState
+	// Time the change was received by the host
+	Time time.Time
+}
+
+// IsFault returns true if the booster went into a short, hot or no-power state.
+func (t BstTransition) IsFault() bool {
+	return t.To.IsShort() || t.To.IsHot() || t.To == bidib.BIDIB_BST_STATE_OFF_NOPOWER
+}
+
+// BstSettings holds the booster settings, as reported by the FEATURE_BST_* features.
+type BstSettings struct {
+	// Output voltage [V]
+	VoltageAdjustable bool
+	Voltage           uint8
+	// Output current limit
+	CurrentAdjustable bool
+	CurrentLimit      bidib.Current
+	// RailCom cutout
+	CutoutAvailable bool
+	CutoutOn        bool
+	// Time until the booster turns off in case of a short
+	TurnOffTime time.Duration
+	// Time until the booster turns off in case of a short after the first power up
+	InrushTurnOffTime time.Duration
+	// Interval of current measurement reports
+	DiagnosticInterval time.Duration
+	// If set, the booster does not turn on automatically when DCC at its input wakes up
+	InhibitAutostart bool
+	// If set, the booster only announces local STOP/GO keys, without acting on them
+	InhibitLocalOnOff bool
+}
+
 // NodeBst provides booster extension on the node.
 type NodeBst struct {
 	*Node
+	// Guards actual state, diagnostics & history
+	stateMutex     sync.RWMutex
 	actualBstState bidib.BstState
 	actualBstDiag  struct {
-		Current     string
-		Voltage     string
-		Temperature string
+		Current     bidib.Current
+		Voltage     bidib.Voltage
+		Temperature bidib.Temperature
 	}
+	history []BstTransition
+}
+
+// newNodeBst constructs a booster extension with unknown diagnostics.
+func newNodeBst(n *Node) *NodeBst {
+	ncs := &NodeBst{Node: n}
+	ncs.actualBstDiag.Current = bidib.CurrentUnknown
+	ncs.actualBstDiag.Voltage = bidib.Voltage(255)
+	ncs.actualBstDiag.Temperature = bidib.Temperature(128)
+	return ncs
 }
 
 // GetState returns the last reported BST state of the node.
 func (ncs *NodeBst) GetState() bidib.BstState {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
 	return ncs.actualBstState
 }
 
 // Gets last reported current
-func (ncs *NodeBst) GetCurrent() string {
+func (ncs *NodeBst) GetCurrent() bidib.Current {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
 	return ncs.actualBstDiag.Current
 }
 
 // Gets last reported voltage
-func (ncs *NodeBst) GetVoltage() string {
+func (ncs *NodeBst) GetVoltage() bidib.Voltage {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
 	return ncs.actualBstDiag.Voltage
 }
 
 // Gets last reported temperature
-func (ncs *NodeBst) GetTemperature() string {
+func (ncs *NodeBst) GetTemperature() bidib.Temperature {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
 	return ncs.actualBstDiag.Temperature
 }
 
+// History returns the last state transitions of the booster (oldest first).
+func (ncs *NodeBst) History() []BstTransition {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
+	return append([]BstTransition(nil), ncs.history...)
+}
+
 // Set the Booster in On state.
+// The command is broadcast to this booster and all boosters below this node.
 func (ncs *NodeBst) On() {
-	ncs.host.postOnQueue(func() {
-		baseMsg := ncs.createBaseMessage()
-		ncs.sendMessages(messages.BoostOn{
-			BaseMessage:     baseMsg,
-			CurrentNodeOnly: false,
-		})
-	})
+	ncs.SetPower(true, false)
 }
 
 // Set the Booster in Off state.
+// The command is broadcast to this booster and all boosters below this node.
 func (ncs *NodeBst) Off() {
-	ncs.host.postOnQueue(func() {
+	ncs.SetPower(false, false)
+}
+
+// SetPower turns the booster on or off.
+// If nodeOnly is set, only this booster is switched (unicast), otherwise the
+// command is broadcast to this booster and all boosters below this node.
+func (ncs *NodeBst) SetPower(on, nodeOnly bool) error {
+	return ncs.host.postOnQueue(func() {
 		baseMsg := ncs.createBaseMessage()
-		ncs.sendMessages(messages.BoostOff{
-			BaseMessage:     baseMsg,
-			CurrentNodeOnly: false,
-		})
+		if on {
+			ncs.sendMessages(messages.BoostOn{
+				BaseMessage:     baseMsg,
+				CurrentNodeOnly: nodeOnly,
+			})
+		} else {
+			ncs.sendMessages(messages.BoostOff{
+				BaseMessage:     baseMsg,
+				CurrentNodeOnly: nodeOnly,
+			})
+		}
+	})
+}
+
+// Query asks the booster to report its state (and diagnostics) again.
+func (ncs *NodeBst) Query() error {
+	return ncs.host.postOnQueue(func() {
+		ncs.query()
 	})
 }
 
+// query sends MSG_BOOST_QUERY.
+// This function is to be called by the message loop.
+func (ncs *NodeBst) query() {
+	ncs.sendMessages(messages.BoostQuery{BaseMessage: ncs.createBaseMessage()})
+}
+
+// Settings returns the booster settings from the features reported by the node.
+func (ncs *NodeBst) Settings() BstSettings {
+	flag := func(id bidib.FeatureID) bool {
+		value, _ := ncs.GetFeature(id)
+		return value != 0
+	}
+	value := func(id bidib.FeatureID) uint8 {
+		value, _ := ncs.GetFeature(id)
+		return value
+	}
+	return BstSettings{
+		VoltageAdjustable:  flag(bidib.FEATURE_BST_VOLT_ADJUSTABLE),
+		Voltage:            value(bidib.FEATURE_BST_VOLT),
+		CurrentAdjustable:  flag(bidib.FEATURE_BST_AMPERE_ADJUSTABLE),
+		CurrentLimit:       bidib.Current(value(bidib.FEATURE_BST_AMPERE)),
+		CutoutAvailable:    flag(bidib.FEATURE_BST_CUTOUT_AVAIALABLE),
+		CutoutOn:           flag(bidib.FEATURE_BST_CUTOUT_ON),
+		TurnOffTime:        time.Duration(value(bidib.FEATURE_BST_TURNOFF_TIME)) * bstTurnOffTimeUnit,
+		InrushTurnOffTime:  time.Duration(value(bidib.FEATURE_BST_INRUSH_TURNOFF_TIME)) * bstTurnOffTimeUnit,
+		DiagnosticInterval: time.Duration(value(bidib.FEATURE_BST_CURMEAS_INTERVAL)) * bstDiagnosticIntervalUnit,
+		InhibitAutostart:   flag(bidib.FEATURE_BST_INHIBIT_AUTOSTART),
+		InhibitLocalOnOff:  flag(bidib.FEATURE_BST_INHIBIT_LOCAL_ONOFF),
+	}
+}
+
+const (
+	// Unit of FEATURE_BST_TURNOFF_TIME & FEATURE_BST_INRUSH_TURNOFF_TIME
+	bstTurnOffTimeUnit = time.Millisecond * 2
+	// Unit of FEATURE_BST_CURMEAS_INTERVAL
+	bstDiagnosticIntervalUnit = time.Millisecond * 10
+)
+
+// SetVoltage changes the output voltage [V] of the booster.
+// Returns the voltage confirmed by the node.
+func (ncs *NodeBst) SetVoltage(ctx context.Context, volts uint8) (uint8, error) {
+	if err := ncs.requireFeature(bidib.FEATURE_BST_VOLT_ADJUSTABLE); err != nil {
+		return 0, err
+	}
+	return ncs.SetFeature(ctx, bidib.FEATURE_BST_VOLT, volts)
+}
+
+// SetCurrentLimit changes the output current limit of the booster.
+// Returns the limit confirmed by the node.
+func (ncs *NodeBst) SetCurrentLimit(ctx context.Context, limit bidib.Current) (bidib.Current, error) {
+	if err := ncs.requireFeature(bidib.FEATURE_BST_AMPERE_ADJUSTABLE); err != nil {
+		return 0, err
+	}
+	value, err := ncs.SetFeature(ctx, bidib.FEATURE_BST_AMPERE, uint8(limit))
+	return bidib.Current(value), err
+}
+
+// SetCutout enables or disables the RailCom cutout of the booster.
+func (ncs *NodeBst) SetCutout(ctx context.Context, on bool) error {
+	if err := ncs.requireFeature(bidib.FEATURE_BST_CUTOUT_AVAIALABLE); err != nil {
+		return err
+	}
+	_, err := ncs.SetFeature(ctx, bidib.FEATURE_BST_CUTOUT_ON, boolFeatureValue(on))
+	return err
+}
+
+// SetTurnOffTime changes the time until the booster turns off in case of a short.
+// Returns the time confirmed by the node.
+func (ncs *NodeBst) SetTurnOffTime(ctx context.Context, d time.Duration) (time.Duration, error) {
+	return ncs.setDurationFeature(ctx, bidib.FEATURE_BST_TURNOFF_TIME, d, bstTurnOffTimeUnit)
+}
+
+// SetInrushTurnOffTime changes the time until the booster turns off in case of a
+// short after the first power up.
+// Returns the time confirmed by the node.
+func (ncs *NodeBst) SetInrushTurnOffTime(ctx context.Context, d time.Duration) (time.Duration, error) {
+	return ncs.setDurationFeature(ctx, bidib.FEATURE_BST_INRUSH_TURNOFF_TIME, d, bstTurnOffTimeUnit)
+}
+
+// SetDiagnosticInterval changes the interval of current measurement reports.
+// Returns the interval confirmed by the node.
+func (ncs *NodeBst) SetDiagnosticInterval(ctx context.Context, d time.Duration) (time.Duration, error) {
+	return ncs.setDurationFeature(ctx, bidib.FEATURE_BST_CURMEAS_INTERVAL, d, bstDiagnosticIntervalUnit)
+}
+
+// SetInhibitAutostart prevents (or allows) the booster to turn on automatically
+// when DCC at its input wakes up.
+func (ncs *NodeBst) SetInhibitAutostart(ctx context.Context, inhibit bool) error {
+	_, err := ncs.SetFeature(ctx, bidib.FEATURE_BST_INHIBIT_AUTOSTART, boolFeatureValue(inhibit))
+	return err
+}
+
+// SetInhibitLocalOnOff makes the booster only announce (or also act on) local STOP/GO keys.
+func (ncs *NodeBst) SetInhibitLocalOnOff(ctx context.Context, inhibit bool) error {
+	_, err := ncs.SetFeature(ctx, bidib.FEATURE_BST_INHIBIT_LOCAL_ONOFF, boolFeatureValue(inhibit))
+	return err
+}
+
+// setDurationFeature sets a feature holding a duration in the given unit.
+func (ncs *NodeBst) setDurationFeature(ctx context.Context, id bidib.FeatureID, d, unit time.Duration) (time.Duration, error) {
+	value := d / unit
+	if value < 0 || value > 255 {
+		return 0, fmt.Errorf("%s out of range for %s", d, id)
+	}
+	confirmed, err := ncs.SetFeature(ctx, id, uint8(value))
+	return time.Duration(confirmed) * unit, err
+}
+
+// requireFeature returns ErrFeatureNotAvailable if the given (boolean) feature is not set.
+func (ncs *NodeBst) requireFeature(id bidib.FeatureID) error {
+	if value, _ := ncs.GetFeature(id); value == 0 {
+		return fmt.Errorf("%w: %s", ErrFeatureNotAvailable, id)
+	}
+	return nil
+}
+
+// boolFeatureValue returns the feature value for the given boolean.
+func boolFeatureValue(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // process the message that is targeted for this node.
 func (ncs *NodeBst) processMessage(m bidib.Message) error {
 	switch m := m.(type) {
 	case messages.BstState:
-		if ncs.actualBstState != m.State {
-			ncs.actualBstState = m.State
-			ncs.invokeNodeChanged(nil)
+		ncs.stateMutex.Lock()
+		previous := ncs.actualBstState
+		changed := compareAndAssign(&ncs.actualBstState, m.State)
+		if changed {
+			ncs.history = append(ncs.history, BstTransition{From: previous, To: m.State, Time: time.Now()})
+			if len(ncs.history) > bstHistoryLimit {
+				ncs.history = append([]BstTransition(nil), ncs.history[len(ncs.history)-bstHistoryLimit:]...)
+			}
+		}
+		ncs.stateMutex.Unlock()
+		if changed {
+			ncs.invokeNodeChanged()
 		}
 		ncs.host.invokeBstStateChanged(m)
 	case messages.BstDiag:
+		ncs.stateMutex.Lock()
 		changed := false
 		if compareAndAssign(&ncs.actualBstDiag.Current, m.Current()) {
 			changed = true
@@ -78,8 +302,20 @@ func (ncs *NodeBst) processMessage(m bidib.Message) error {
 		if compareAndAssign(&ncs.actualBstDiag.Temperature, m.Temperature()) {
 			changed = true
 		}
+		ncs.stateMutex.Unlock()
+		if changed {
+			ncs.invokeNodeChanged()
+		}
+	case messages.BstCurrent:
+		if ncs.SupportsBoostDiagnostic() {
+			// Deprecated since protocol 0.10; the current is taken from MSG_BOOST_DIAGNOSTIC
+			return nil
+		}
+		ncs.stateMutex.Lock()
+		changed := compareAndAssign(&ncs.actualBstDiag.Current, m.Current)
+		ncs.stateMutex.Unlock()
 		if changed {
-			ncs.invokeNodeChanged(nil)
+			ncs.invokeNodeChanged()
 		}
 	}
 	return nil
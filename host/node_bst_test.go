@@ -0,0 +1,121 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const classBst = bidib.ClassID(1 << 1)
+
+func TestBstSetPower(t *testing.T) {
+	tests := []struct {
+		on, nodeOnly bool
+		expect       bidib.Message
+	}{
+		{on: true, expect: messages.BoostOn{}},
+		{on: true, nodeOnly: true, expect: messages.BoostOn{CurrentNodeOnly: true}},
+		{on: false, expect: messages.BoostOff{}},
+		{on: false, nodeOnly: true, expect: messages.BoostOff{CurrentNodeOnly: true}},
+	}
+	for _, tc := range tests {
+		conn := &fakeConnection{}
+		h := newTestHost(t, conn)
+		n := setupTestNodeClass(h, classBst, nil)
+		require.NoError(t, n.Bst().SetPower(tc.on, tc.nodeOnly))
+		h.syncQueue(t)
+		assert.Equal(t, []bidib.Message{tc.expect}, conn.sentMessages())
+	}
+}
+
+func TestBstQuery(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := setupTestNodeClass(h, classBst, nil)
+	require.NoError(t, n.Bst().Query())
+	h.syncQueue(t)
+	assert.Equal(t, []bidib.Message{messages.BoostQuery{}}, conn.sentMessages())
+}
+
+func TestBstSettings(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := setupTestNodeClass(h, classBst, map[bidib.FeatureID]uint8{
+		bidib.FEATURE_BST_VOLT_ADJUSTABLE:     1,
+		bidib.FEATURE_BST_VOLT:                16,
+		bidib.FEATURE_BST_AMPERE:              40,
+		bidib.FEATURE_BST_CUTOUT_AVAIALABLE:   1,
+		bidib.FEATURE_BST_TURNOFF_TIME:        5,
+		bidib.FEATURE_BST_INRUSH_TURNOFF_TIME: 50,
+		bidib.FEATURE_BST_CURMEAS_INTERVAL:    20,
+		bidib.FEATURE_BST_INHIBIT_LOCAL_ONOFF: 1,
+	})
+	assert.Equal(t, BstSettings{
+		VoltageAdjustable:  true,
+		Voltage:            16,
+		CurrentLimit:       bidib.Current(40),
+		CutoutAvailable:    true,
+		TurnOffTime:        time.Millisecond * 10,
+		InrushTurnOffTime:  time.Millisecond * 100,
+		DiagnosticInterval: time.Millisecond * 200,
+		InhibitLocalOnOff:  true,
+	}, n.Bst().Settings())
+}
+
+func TestBstHistory(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := setupTestNodeClass(h, classBst, nil)
+	states := []bidib.BstState{
+		bidib.BIDIB_BST_STATE_ON,
+		bidib.BIDIB_BST_STATE_OFF_SHORT,
+	}
+	// Unchanged state is not a transition
+	h.reply(n.Address, bidib.MSG_BOOST_STAT, messages.BstState{State: bidib.BIDIB_BST_STATE_OFF})
+	h.syncQueue(t)
+	assert.Empty(t, n.Bst().History())
+
+	for i := 0; i < bstHistoryLimit+3; i++ {
+		h.reply(n.Address, bidib.MSG_BOOST_STAT, messages.BstState{State: states[i%2]})
+	}
+	h.syncQueue(t)
+	history := n.Bst().History()
+	require.Len(t, history, bstHistoryLimit)
+	// Oldest transitions are dropped
+	assert.Equal(t, bidib.BIDIB_BST_STATE_ON, history[0].From)
+	assert.Equal(t, bidib.BIDIB_BST_STATE_OFF_SHORT, history[0].To)
+	assert.True(t, history[0].IsFault())
+	last := history[len(history)-1]
+	assert.Equal(t, bidib.BIDIB_BST_STATE_OFF_SHORT, last.From)
+	assert.Equal(t, bidib.BIDIB_BST_STATE_ON, last.To)
+	assert.False(t, last.IsFault())
+	assert.Equal(t, bidib.BIDIB_BST_STATE_ON, n.Bst().GetState())
+}
+
+func TestBstCurrent(t *testing.T) {
+	tests := []struct {
+		name          string
+		major, minor  uint8
+		expectCurrent bidib.Current
+	}{
+		{name: "before diagnostics", major: 0, minor: 9, expectCurrent: 20},
+		{name: "with diagnostics", major: 0, minor: 10, expectCurrent: 10},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newTestHost(t, &fakeConnection{})
+			n := setupTestNodeClass(h, classBst, nil)
+			h.reply(n.Address, bidib.MSG_SYS_P_VERSION, messages.SysPVersion{Major: tc.major, Minor: tc.minor})
+			h.reply(n.Address, bidib.MSG_BOOST_DIAGNOSTIC, messages.BstDiag{DiagI: 10, DiagV: 16, DiagTemp: 40})
+			h.reply(n.Address, bidib.MSG_BOOST_CURRENT, messages.BstCurrent{Current: 20})
+			h.syncQueue(t)
+			bst := n.Bst()
+			assert.Equal(t, tc.expectCurrent, bst.GetCurrent())
+			assert.Equal(t, bidib.Voltage(16), bst.GetVoltage())
+			assert.Equal(t, bidib.Temperature(40), bst.GetTemperature())
+		})
+	}
+}
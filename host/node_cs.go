@@ -1,6 +1,9 @@
 package host
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/binkynet/bidib"
@@ -10,11 +13,52 @@ import (
 // NodeCs provides commandstation extension on the node.
 type NodeCs struct {
 	*Node
+	// Guards actualCsState
+	stateMutex                    sync.RWMutex
 	actualCsState, desiredCsState bidib.CsState
+	// Last drive state send per DCC address
+	locos struct {
+		mutex sync.Mutex
+		all   map[uint16]*DriveOptions
+		// Last binary states send per DCC address
+		binStates map[uint16]map[uint16]bool
+	}
+	// Serializes programming track operations
+	progMutex sync.Mutex
+}
+
+const (
+	// Time to wait for a programming track operation to finish
+	progTimeout = time.Second * 5
+)
+
+// ProgError is returned when a programming track operation failed.
+type ProgError struct {
+	// Final state reported in MSG_CS_PROG_STATE
+	State uint8
+}
+
+func (e ProgError) Error() string {
+	switch e.State {
+	case bidib.BIDIB_CS_PROG_STOPPED:
+		return "programming stopped"
+	case bidib.BIDIB_CS_PROG_NO_LOCO:
+		return "no loco on programming track"
+	case bidib.BIDIB_CS_PROG_NO_ANSWER:
+		return "no answer from loco"
+	case bidib.BIDIB_CS_PROG_SHORT:
+		return "short on programming track"
+	case bidib.BIDIB_CS_PROG_VERIFY_FAILED:
+		return "verify failed"
+	default:
+		return fmt.Sprintf("programming failed (state=0x%02x)", e.State)
+	}
 }
 
 // GetState returns the last reported CS state of the node.
 func (ncs *NodeCs) GetState() bidib.CsState {
+	ncs.stateMutex.RLock()
+	defer ncs.stateMutex.RUnlock()
 	return ncs.actualCsState
 }
 
@@ -64,7 +108,149 @@ type DriveOptions struct {
 }
 
 // Drive instructs the DCC generator to output given drive options
-func (ncs *NodeCs) Drive(opts DriveOptions) {
+func (ncs *NodeCs) Drive(opts DriveOptions) error {
+	opts.Flags = opts.Flags.Clone()
+	return ncs.host.postOnQueue(func() {
+		ncs.rememberDrive(opts)
+		ncs.sendDrive(opts)
+	})
+}
+
+// SetFunction switches the function with given number (0..68) of the loco
+// with given address on or off.
+// F0-F28 are send as part of a drive command, higher functions are send
+// as binary state.
+func (ncs *NodeCs) SetFunction(dccAddress uint16, function int, on bool) error {
+	if function < 0 || function > bidib.MaxDccFunction {
+		return fmt.Errorf("function %d out of range [0-%d]", function, bidib.MaxDccFunction)
+	}
+	return ncs.host.postOnQueue(func() {
+		ncs.setFunction(dccAddress, function, on)
+	})
+}
+
+// ToggleFunction inverts the last send state of the function with given number (0..68)
+// of the loco with given address.
+func (ncs *NodeCs) ToggleFunction(dccAddress uint16, function int) error {
+	if function < 0 || function > bidib.MaxDccFunction {
+		return fmt.Errorf("function %d out of range [0-%d]", function, bidib.MaxDccFunction)
+	}
+	return ncs.host.postOnQueue(func() {
+		ncs.locos.mutex.Lock()
+		on := !ncs.getLoco(dccAddress).Flags.Get(function)
+		ncs.locos.mutex.Unlock()
+		ncs.setFunction(dccAddress, function, on)
+	})
+}
+
+// GetFunction returns the last send state of the function with given number
+// of the loco with given address.
+func (ncs *NodeCs) GetFunction(dccAddress uint16, function int) bool {
+	ncs.locos.mutex.Lock()
+	defer ncs.locos.mutex.Unlock()
+	if loco, found := ncs.locos.all[dccAddress]; found {
+		return loco.Flags.Get(function)
+	}
+	return false
+}
+
+// SetBinState sends a binary state (1..32767) to the loco with given address.
+func (ncs *NodeCs) SetBinState(dccAddress uint16, state uint16, on bool) error {
+	if state == 0 || state > 32767 {
+		return fmt.Errorf("binary state %d out of range [1-32767]", state)
+	}
+	return ncs.host.postOnQueue(func() {
+		ncs.locos.mutex.Lock()
+		if ncs.locos.binStates == nil {
+			ncs.locos.binStates = make(map[uint16]map[uint16]bool)
+		}
+		states := ncs.locos.binStates[dccAddress]
+		if states == nil {
+			states = make(map[uint16]bool)
+			ncs.locos.binStates[dccAddress] = states
+		}
+		states[state] = on
+		ncs.locos.mutex.Unlock()
+		ncs.sendBinState(dccAddress, state, on)
+	})
+}
+
+// GetBinState returns the last state send using SetBinState for the binary state
+// with given number of the loco with given address.
+func (ncs *NodeCs) GetBinState(dccAddress uint16, state uint16) bool {
+	ncs.locos.mutex.Lock()
+	defer ncs.locos.mutex.Unlock()
+	return ncs.locos.binStates[dccAddress][state]
+}
+
+// setFunction records the new function state and sends it to the loco.
+// This function is to be called by the message loop.
+func (ncs *NodeCs) setFunction(dccAddress uint16, function int, on bool) {
+	ncs.locos.mutex.Lock()
+	loco := ncs.getLoco(dccAddress)
+	loco.Flags.Set(function, on)
+	opts := *loco
+	opts.Flags = loco.Flags.Clone()
+	ncs.locos.mutex.Unlock()
+	if function > bidib.MaxDriveFunction {
+		ncs.sendBinState(dccAddress, uint16(function), on)
+		return
+	}
+	opts.OutputSpeed = false
+	opts.OutputF1_F4 = function <= 4
+	opts.OutputF5_F8 = function >= 5 && function <= 8
+	opts.OutputF9_F12 = function >= 9 && function <= 12
+	opts.OutputF13_F20 = function >= 13 && function <= 20
+	opts.OutputF21_F28 = function >= 21
+	ncs.sendDrive(opts)
+}
+
+// getLoco returns the last send drive state of the loco with given address.
+// The locos mutex must be held by the caller.
+func (ncs *NodeCs) getLoco(dccAddress uint16) *DriveOptions {
+	if loco, found := ncs.locos.all[dccAddress]; found {
+		return loco
+	}
+	if ncs.locos.all == nil {
+		ncs.locos.all = make(map[uint16]*DriveOptions)
+	}
+	loco := &DriveOptions{
+		DccAddress:       dccAddress,
+		DccFormat:        bidib.BIDIB_CS_DRIVE_FORMAT_DCC128,
+		DirectionForward: true,
+		Flags:            make(bidib.DccFlags, bidib.MaxDccFunction+1),
+	}
+	ncs.locos.all[dccAddress] = loco
+	return loco
+}
+
+// rememberDrive updates the loco state with the parts of the given drive options
+// that are output.
+func (ncs *NodeCs) rememberDrive(opts DriveOptions) {
+	ncs.locos.mutex.Lock()
+	defer ncs.locos.mutex.Unlock()
+	loco := ncs.getLoco(opts.DccAddress)
+	loco.DccFormat = opts.DccFormat
+	if opts.OutputSpeed {
+		loco.Speed = opts.Speed
+		loco.DirectionForward = opts.DirectionForward
+	}
+	copyFlags := func(output bool, start, end int) {
+		if output {
+			for i := start; i <= end; i++ {
+				loco.Flags.Set(i, opts.Flags.Get(i))
+			}
+		}
+	}
+	copyFlags(opts.OutputF1_F4, 0, 4)
+	copyFlags(opts.OutputF5_F8, 5, 8)
+	copyFlags(opts.OutputF9_F12, 9, 12)
+	copyFlags(opts.OutputF13_F20, 13, 20)
+	copyFlags(opts.OutputF21_F28, 21, 28)
+}
+
+// sendDrive sends a drive command for the given options.
+func (ncs *NodeCs) sendDrive(opts DriveOptions) {
 	baseMsg := ncs.createBaseMessage()
 	ncs.sendMessages(messages.CsDrive{
 		BaseMessage:      baseMsg,
@@ -82,6 +268,21 @@ func (ncs *NodeCs) Drive(opts DriveOptions) {
 	})
 }
 
+// sendBinState sends a binary state command.
+func (ncs *NodeCs) sendBinState(dccAddress uint16, state uint16, on bool) {
+	baseMsg := ncs.createBaseMessage()
+	data := uint8(0)
+	if on {
+		data = 1
+	}
+	ncs.sendMessages(messages.CsBinState{
+		BaseMessage: baseMsg,
+		DccAddress:  dccAddress,
+		State:       state,
+		Data:        data,
+	})
+}
+
 // Program performs a programming operation
 // cv: 1..1024
 func (ncs *NodeCs) Program(opcode bidib.CsProgOpCode, cv uint16, data uint8) {
@@ -100,6 +301,59 @@ func (ncs *NodeCs) Program(opcode bidib.CsProgOpCode, cv uint16, data uint8) {
 	})
 }
 
+// ReadCV reads the value of the given CV (1..1024) of the loco on the programming track.
+func (ncs *NodeCs) ReadCV(ctx context.Context, cv uint16) (uint8, error) {
+	return ncs.programSync(ctx, bidib.BIDIB_CS_PROG_RD_BYTE, cv, 0)
+}
+
+// WriteCV writes the value of the given CV (1..1024) of the loco on the programming track.
+func (ncs *NodeCs) WriteCV(ctx context.Context, cv uint16, value uint8) error {
+	_, err := ncs.programSync(ctx, bidib.BIDIB_CS_PROG_WR_BYTE, cv, value)
+	return err
+}
+
+// programSync performs a programming operation and waits for it to finish.
+// Returns the data reported by the node.
+func (ncs *NodeCs) programSync(ctx context.Context, opcode bidib.CsProgOpCode, cv uint16, data uint8) (uint8, error) {
+	if cv < 1 || cv > 1024 {
+		return 0, fmt.Errorf("cv %d out of range [1-1024]", cv)
+	}
+	ncs.progMutex.Lock()
+	defer ncs.progMutex.Unlock()
+
+	var result uint8
+	keys := []responseKey{ncs.responseKey(bidib.MSG_CS_PROG_STATE, 0)}
+	opts := requestOptions{timeout: progTimeout}
+	baseMsg := ncs.createBaseMessage()
+	ncs.host.postOnQueue(func() {
+		ncs.desiredCsState = bidib.BIDIB_CS_STATE_PROG
+	})
+	err := ncs.host.request(ctx, ncs.Node, opts, keys, func(m bidib.Message) (bool, error) {
+		ps, ok := m.(messages.CsProgState)
+		if !ok || ps.State&bidib.BIDIB_CS_PROG_OKAY == 0 {
+			// Still running
+			return false, nil
+		}
+		if ps.State != bidib.BIDIB_CS_PROG_OKAY {
+			return true, ProgError{State: ps.State}
+		}
+		result = ps.Data
+		return true, nil
+	}, messages.CsSetState{
+		BaseMessage: baseMsg,
+		State:       bidib.BIDIB_CS_STATE_PROG,
+	}, messages.CsProg{
+		BaseMessage: baseMsg,
+		OpCode:      opcode,
+		Cv:          cv - 1,
+		Data:        data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
 type ProgramOnMainOptions struct {
 	OpCode     bidib.CsPomOpCode
 	DccAddress uint32
@@ -141,21 +395,19 @@ func (ncs *NodeCs) setState(state bidib.CsState) {
 func (ncs *NodeCs) processMessage(m bidib.Message) error {
 	switch m := m.(type) {
 	case messages.CsState:
-		if ncs.actualCsState != m.State {
-			ncs.actualCsState = m.State
-			ncs.invokeNodeChanged(nil)
+		ncs.stateMutex.Lock()
+		changed := compareAndAssign(&ncs.actualCsState, m.State)
+		ncs.stateMutex.Unlock()
+		if changed {
+			ncs.invokeNodeChanged()
 		}
 	case messages.BmCv:
-		opts := ProgramOnMainOptions{
+		ncs.host.invokePomResult(PomResultEvent{
+			Node:       ncs.Node,
 			DccAddress: uint32(m.DccAddress),
 			Cv:         uint32(m.Cv) + 1,
 			Data:       m.Data,
-		}
-		ncs.invokeNodeChanged(opts)
-	case messages.BmDynState:
-		ncs.host.invokeDynStateChanged(m)
-	case messages.BmAddress:
-		ncs.host.invokeBmAdressChanged(m)
+		})
 	}
 	return nil
 }
@@ -0,0 +1,60 @@
+package host
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestNodeCsFunctions(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	ncs := &NodeCs{Node: h.intfNode}
+
+	// F0-F28 are send as drive command
+	require.NoError(t, ncs.SetFunction(3, 2, true))
+	h.syncQueue(t)
+	drive, ok := conn.lastSent().(messages.CsDrive)
+	require.True(t, ok)
+	assert.Equal(t, uint16(3), drive.DccAddress)
+	assert.True(t, drive.OutputF1_F4)
+	assert.False(t, drive.OutputSpeed)
+	assert.True(t, drive.Flags.Get(2))
+	assert.True(t, ncs.GetFunction(3, 2))
+
+	// F29 and up are send as binary state
+	require.NoError(t, ncs.SetFunction(3, 30, true))
+	h.syncQueue(t)
+	assert.Equal(t, messages.CsBinState{BaseMessage: ncs.createBaseMessage(), DccAddress: 3, State: 30, Data: 1}, conn.lastSent())
+	assert.True(t, ncs.GetFunction(3, 30))
+
+	// Toggle inverts the last send state
+	require.NoError(t, ncs.ToggleFunction(3, 2))
+	require.NoError(t, ncs.ToggleFunction(3, 4))
+	h.syncQueue(t)
+	assert.False(t, ncs.GetFunction(3, 2))
+	assert.True(t, ncs.GetFunction(3, 4))
+	assert.False(t, ncs.GetFunction(4, 4))
+
+	// Binary states are kept separate from functions
+	require.NoError(t, ncs.SetBinState(3, 5, true))
+	h.syncQueue(t)
+	assert.Equal(t, messages.CsBinState{BaseMessage: ncs.createBaseMessage(), DccAddress: 3, State: 5, Data: 1}, conn.lastSent())
+	assert.True(t, ncs.GetBinState(3, 5))
+	assert.False(t, ncs.GetFunction(3, 5))
+
+	assert.Error(t, ncs.SetFunction(3, bidib.MaxDccFunction+1, true))
+	assert.Error(t, ncs.SetBinState(3, 0, true))
+}
+
+func TestNodeCsDriveClosed(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	ncs := &NodeCs{Node: h.intfNode}
+	atomic.StoreUint32(&h.closed, 1)
+	assert.ErrorIs(t, ncs.Drive(DriveOptions{DccAddress: 3}), ErrClosed)
+}
@@ -0,0 +1,414 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// PortType is the type of a port of a light/switch control node.
+type PortType uint8
+
+const (
+	PortTypeSwitch     PortType = bidib.BIDIB_PORTTYPE_SWITCH
+	PortTypeLight      PortType = bidib.BIDIB_PORTTYPE_LIGHT
+	PortTypeServo      PortType = bidib.BIDIB_PORTTYPE_SERVO
+	PortTypeSound      PortType = bidib.BIDIB_PORTTYPE_SOUND
+	PortTypeMotor      PortType = bidib.BIDIB_PORTTYPE_MOTOR
+	PortTypeAnalogOut  PortType = bidib.BIDIB_PORTTYPE_ANALOGOUT
+	PortTypeBacklight  PortType = bidib.BIDIB_PORTTYPE_BACKLIGHT
+	PortTypeSwitchPair PortType = bidib.BIDIB_PORTTYPE_SWITCHPAIR
+	PortTypeInput      PortType = bidib.BIDIB_PORTTYPE_INPUT
+	// Type of a port in the flat port model, before its configuration is known
+	PortTypeUnknown PortType = 0xFF
+)
+
+func (t PortType) String() string {
+	switch t {
+	case PortTypeSwitch:
+		return "switch"
+	case PortTypeLight:
+		return "light"
+	case PortTypeServo:
+		return "servo"
+	case PortTypeSound:
+		return "sound"
+	case PortTypeMotor:
+		return "motor"
+	case PortTypeAnalogOut:
+		return "analog"
+	case PortTypeBacklight:
+		return "backlight"
+	case PortTypeSwitchPair:
+		return "switch-pair"
+	case PortTypeInput:
+		return "input"
+	case PortTypeUnknown:
+		return "unknown"
+	default:
+		return fmt.Sprintf("PortType(%d)", uint8(t))
+	}
+}
+
+// LightAction is a command for a light port.
+type LightAction uint8
+
+const (
+	LightOff         LightAction = bidib.BIDIB_PORT_TURN_OFF
+	LightOn          LightAction = bidib.BIDIB_PORT_TURN_ON
+	LightDimOff      LightAction = bidib.BIDIB_PORT_DIMM_OFF
+	LightDimOn       LightAction = bidib.BIDIB_PORT_DIMM_ON
+	LightNeon        LightAction = bidib.BIDIB_PORT_TURN_ON_NEON
+	LightBlinkA      LightAction = bidib.BIDIB_PORT_BLINK_A
+	LightBlinkB      LightAction = bidib.BIDIB_PORT_BLINK_B
+	LightFlashA      LightAction = bidib.BIDIB_PORT_FLASH_A
+	LightFlashB      LightAction = bidib.BIDIB_PORT_FLASH_B
+	LightDoubleFlash LightAction = bidib.BIDIB_PORT_DOUBLE_FLASH
+)
+
+var (
+	// Returned when a node reports that a port is not available (MSG_LC_NA).
+	ErrPortNotAvailable = errors.New("port not available")
+)
+
+// PortError is returned when a node reports MSG_LC_NA for a port.
+type PortError struct {
+	Port messages.LcPort
+	// Set if the node reported a cause
+	HasCause bool
+	Cause    uint8
+}
+
+func (e PortError) Error() string {
+	if e.HasCause {
+		return fmt.Sprintf("port %s not available (cause 0x%02x)", e.Port, e.Cause)
+	}
+	return fmt.Sprintf("port %s not available", e.Port)
+}
+
+// Unwrap returns ErrPortNotAvailable, so errors.Is can be used.
+func (e PortError) Unwrap() error {
+	return ErrPortNotAvailable
+}
+
+// Port is the last reported state of a port.
+type Port struct {
+	// Address of the port
+	Address messages.LcPort
+	// Type of the port
+	Type PortType
+	// Last reported state (opcode or value, depending on the type)
+	State uint8
+	// Set while the port is executing a command (MSG_LC_WAIT)
+	Waiting bool
+	// Last reported configuration
+	Config PortConfig
+	// Last reported error (nil if none)
+	Error *PortError
+}
+
+// clone returns a copy of the port that does not share memory with the original.
+func (p Port) clone() Port {
+	p.Config = p.Config.clone()
+	return p
+}
+
+// Features holding the number of ports per type (typed port model)
+var typedPortCountFeatures = []struct {
+	Type    PortType
+	Feature bidib.FeatureID
+}{
+	{PortTypeSwitch, bidib.FEATURE_CTRL_SWITCH_COUNT},
+	{PortTypeLight, bidib.FEATURE_CTRL_LIGHT_COUNT},
+	{PortTypeServo, bidib.FEATURE_CTRL_SERVO_COUNT},
+	{PortTypeSound, bidib.FEATURE_CTRL_SOUND_COUNT},
+	{PortTypeMotor, bidib.FEATURE_CTRL_MOTOR_COUNT},
+	{PortTypeAnalogOut, bidib.FEATURE_CTRL_ANALOGOUT_COUNT},
+	{PortTypeBacklight, bidib.FEATURE_CTRL_BACKLIGHT_COUNT},
+	{PortTypeInput, bidib.FEATURE_CTRL_INPUT_COUNT},
+}
+
+// NodeLc provides light/switch control extension on the node.
+type NodeLc struct {
+	*Node
+	// Guards ports & macro states
+	stateMutex  sync.RWMutex
+	ports       []*Port
+	portIndex   map[messages.LcPort]*Port
+	macroStates map[uint8]MacroState
+}
+
+// newNodeLc constructs a light/switch control extension without known ports.
+func newNodeLc(n *Node) *NodeLc {
+	return &NodeLc{
+		Node:      n,
+		portIndex: make(map[messages.LcPort]*Port),
+	}
+}
+
+// IsFlatModel returns true if the node uses the flat port model.
+func (nlc *NodeLc) IsFlatModel() bool {
+	return nlc.flatPortCount() > 0
+}
+
+// flatPortCount returns the number of ports in the flat port model.
+func (nlc *NodeLc) flatPortCount() int {
+	low, _ := nlc.GetFeature(bidib.FEATURE_CTRL_PORT_FLAT_MODEL)
+	high, _ := nlc.GetFeature(bidib.FEATURE_CTRL_PORT_FLAT_MODEL_EXTENDED)
+	return int(low) + 256*int(high)
+}
+
+// GetPort returns the last reported state of the port with given address.
+// Returns port, found
+func (nlc *NodeLc) GetPort(port messages.LcPort) (Port, bool) {
+	nlc.stateMutex.RLock()
+	defer nlc.stateMutex.RUnlock()
+	p, found := nlc.portIndex[port]
+	if !found {
+		return Port{}, false
+	}
+	return p.clone(), true
+}
+
+// Ports returns the last reported state of all ports.
+func (nlc *NodeLc) Ports() []Port {
+	nlc.stateMutex.RLock()
+	defer nlc.stateMutex.RUnlock()
+	result := make([]Port, 0, len(nlc.ports))
+	for _, p := range nlc.ports {
+		result = append(result, p.clone())
+	}
+	return result
+}
+
+// SetSwitch turns a switch port on or off.
+func (nlc *NodeLc) SetSwitch(ctx context.Context, port messages.LcPort, on bool) error {
+	state := uint8(bidib.BIDIB_PORT_TURN_OFF)
+	if on {
+		state = bidib.BIDIB_PORT_TURN_ON
+	}
+	return nlc.output(ctx, port, PortTypeSwitch, state)
+}
+
+// SetLight executes the given action on a light port.
+func (nlc *NodeLc) SetLight(ctx context.Context, port messages.LcPort, action LightAction) error {
+	return nlc.output(ctx, port, PortTypeLight, uint8(action))
+}
+
+// SetServo moves a servo port to the given position (0-255).
+func (nlc *NodeLc) SetServo(ctx context.Context, port messages.LcPort, position uint8) error {
+	return nlc.output(ctx, port, PortTypeServo, position)
+}
+
+// SetMotor sets the speed of a motor port.
+func (nlc *NodeLc) SetMotor(ctx context.Context, port messages.LcPort, speed uint8) error {
+	return nlc.output(ctx, port, PortTypeMotor, speed)
+}
+
+// SetAnalog sets the value of an analog output port.
+func (nlc *NodeLc) SetAnalog(ctx context.Context, port messages.LcPort, value uint8) error {
+	return nlc.output(ctx, port, PortTypeAnalogOut, value)
+}
+
+// SetBacklight sets the intensity of a backlight port.
+func (nlc *NodeLc) SetBacklight(ctx context.Context, port messages.LcPort, intensity uint8) error {
+	return nlc.output(ctx, port, PortTypeBacklight, intensity)
+}
+
+// SetRGB changes the color of a coloured output.
+func (nlc *NodeLc) SetRGB(ctx context.Context, port messages.LcPort, color RGB) error {
+	_, err := nlc.WriteConfig(ctx, port, PortConfig{RGB: &color})
+	return err
+}
+
+// QueryPort requests the state of the port with given address.
+func (nlc *NodeLc) QueryPort(ctx context.Context, port messages.LcPort) (Port, error) {
+	keys := nlc.portResponseKeys(port, bidib.MSG_LC_STAT)
+	if err := nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		return true, portResponseError(m)
+	}, messages.LcPortQuery{BaseMessage: nlc.createBaseMessage(), Port: port}); err != nil {
+		return Port{}, err
+	}
+	p, _ := nlc.GetPort(port)
+	return p, nil
+}
+
+// ReadConfig reads the configuration of the port with given address.
+func (nlc *NodeLc) ReadConfig(ctx context.Context, port messages.LcPort) (PortConfig, error) {
+	return nlc.configRequest(ctx, port, messages.LcConfigXGet{
+		BaseMessage: nlc.createBaseMessage(),
+		Port:        port,
+	})
+}
+
+// WriteConfig writes the parameters that are set in the given configuration
+// to the port with given address.
+// Returns the configuration confirmed by the node.
+func (nlc *NodeLc) WriteConfig(ctx context.Context, port messages.LcPort, cfg PortConfig) (PortConfig, error) {
+	params := cfg.params()
+	if len(params) == 0 {
+		return PortConfig{}, fmt.Errorf("no configuration parameters set")
+	}
+	return nlc.configRequest(ctx, port, messages.LcConfigXSet{
+		BaseMessage: nlc.createBaseMessage(),
+		Port:        port,
+		Params:      params,
+	})
+}
+
+// output sends MSG_LC_OUTPUT to a port of the given type and waits for the node to accept it.
+func (nlc *NodeLc) output(ctx context.Context, port messages.LcPort, t PortType, state uint8) error {
+	if err := nlc.checkPortType(port, t); err != nil {
+		return err
+	}
+	keys := nlc.portResponseKeys(port, bidib.MSG_LC_STAT, bidib.MSG_LC_WAIT)
+	return nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		return true, portResponseError(m)
+	}, messages.LcOutput{BaseMessage: nlc.createBaseMessage(), Port: port, State: state})
+}
+
+// configRequest sends the given message and collects the MSG_LC_CONFIGX response(s).
+func (nlc *NodeLc) configRequest(ctx context.Context, port messages.LcPort, m bidib.Message) (PortConfig, error) {
+	var result PortConfig
+	keys := nlc.portResponseKeys(port, bidib.MSG_LC_CONFIGX)
+	err := nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if m, ok := m.(messages.LcConfigX); ok {
+			result.merge(m.Params)
+			return !m.HasContinuation(), nil
+		}
+		return true, portResponseError(m)
+	}, m)
+	if err != nil {
+		return PortConfig{}, err
+	}
+	return result, nil
+}
+
+// checkPortType returns an error if the port with given address is known to have another type.
+func (nlc *NodeLc) checkPortType(port messages.LcPort, t PortType) error {
+	if !nlc.IsFlatModel() {
+		if PortType(port.Type()) != t {
+			return fmt.Errorf("port %s is not a %s port", port, t)
+		}
+		return nil
+	}
+	if p, found := nlc.GetPort(port); found && p.Type != PortTypeUnknown && p.Type != t {
+		return fmt.Errorf("port %s is a %s port, not a %s port", port, p.Type, t)
+	}
+	return nil
+}
+
+// portResponseKeys returns the response keys for a request on a port,
+// including MSG_LC_NA.
+func (nlc *NodeLc) portResponseKeys(port messages.LcPort, mTypes ...bidib.MessageType) []responseKey {
+	key := lcPortKey(port)
+	result := []responseKey{nlc.responseKey(bidib.MSG_LC_NA, key)}
+	for _, mType := range mTypes {
+		result = append(result, nlc.responseKey(mType, key))
+	}
+	return result
+}
+
+// portResponseError returns a PortError if the given message is MSG_LC_NA.
+func portResponseError(m bidib.Message) error {
+	if m, ok := m.(messages.LcNa); ok {
+		return PortError{Port: m.Port, HasCause: m.HasCause, Cause: m.Cause}
+	}
+	return nil
+}
+
+// lcPortKey returns the response key for a port message.
+func lcPortKey(port messages.LcPort) uint32 {
+	return uint32(port.Flat())
+}
+
+// discover builds the list of ports from the features & queries their
+// configuration & state.
+// This function is to be called by the message loop.
+func (nlc *NodeLc) discover() {
+	var ports []*Port
+	if count := nlc.flatPortCount(); count > 0 {
+		for i := 0; i < count; i++ {
+			ports = append(ports, &Port{Address: messages.FlatPort(uint16(i)), Type: PortTypeUnknown})
+		}
+	} else {
+		for _, tf := range typedPortCountFeatures {
+			count, _ := nlc.GetFeature(tf.Feature)
+			for i := 0; i < int(count); i++ {
+				ports = append(ports, &Port{Address: messages.TypedPort(uint8(tf.Type), uint8(i)), Type: tf.Type})
+			}
+		}
+	}
+	nlc.stateMutex.Lock()
+	nlc.ports = ports
+	nlc.portIndex = make(map[messages.LcPort]*Port, len(ports))
+	for _, p := range ports {
+		nlc.portIndex[p.Address] = p
+	}
+	nlc.stateMutex.Unlock()
+	if len(ports) == 0 {
+		return
+	}
+
+	baseMsg := nlc.createBaseMessage()
+	msgs := []bidib.Message{messages.LcConfigXGetAll{BaseMessage: baseMsg}}
+	if available, _ := nlc.GetFeature(bidib.FEATURE_CTRL_PORT_QUERY_AVAILABLE); available != 0 {
+		msgs = append(msgs, messages.LcPortQueryAll{BaseMessage: baseMsg, Select: 0xFFFF})
+	}
+	nlc.sendMessages(msgs...)
+}
+
+// updatePort calls the given function for the port with given address (if known)
+// and invokes the node changed handlers.
+func (nlc *NodeLc) updatePort(port messages.LcPort, update func(*Port)) {
+	nlc.stateMutex.Lock()
+	p, found := nlc.portIndex[port]
+	if found {
+		update(p)
+	}
+	nlc.stateMutex.Unlock()
+	if found {
+		nlc.invokeNodeChanged()
+	} else {
+		nlc.log.Debug().Str("port", port.String()).Msg("Received message for unknown port")
+	}
+}
+
+// process the message that is targeted for this node.
+func (nlc *NodeLc) processMessage(m bidib.Message) error {
+	switch m := m.(type) {
+	case messages.LcStat:
+		nlc.updatePort(m.Port, func(p *Port) {
+			p.State = m.State
+			p.Waiting = false
+			p.Error = nil
+		})
+	case messages.LcWait:
+		nlc.updatePort(m.Port, func(p *Port) {
+			p.Waiting = true
+		})
+	case messages.LcNa:
+		if m.Port == messages.LcPortAll {
+			// End of MSG_LC_PORT_QUERY_ALL
+			return nil
+		}
+		nlc.updatePort(m.Port, func(p *Port) {
+			p.Waiting = false
+			p.Error = &PortError{Port: m.Port, HasCause: m.HasCause, Cause: m.Cause}
+		})
+	case messages.LcConfigX:
+		nlc.updatePort(m.Port, func(p *Port) {
+			p.Config.merge(m.Params)
+			if r := p.Config.Reconfig; r != nil {
+				p.Type = r.Type
+			}
+		})
+	case messages.LcMacroState:
+		nlc.setMacroState(m.MacroNum, MacroState(m.State))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package host
+
+import (
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// RGB is the color of a coloured output.
+type RGB struct {
+	R, G, B uint8
+}
+
+// PortReconfig is the port type configuration of a port in the flat port model.
+type PortReconfig struct {
+	// Actual type of the port
+	Type PortType
+	// Bitmask of port types supported by the port (bit n = type n)
+	PortMap uint16
+}
+
+// Supports returns true if the port can be configured as the given type.
+func (r PortReconfig) Supports(t PortType) bool {
+	return t < 16 && r.PortMap&(1<<t) != 0
+}
+
+// PortConfig is the configuration of a port (MSG_LC_CONFIGX).
+// Fields are nil when the node did not report the parameter.
+type PortConfig struct {
+	// 'Analog' value for ON & OFF
+	LevelOn, LevelOff *uint8
+	// Dim rate [unit 1/255 absolute brightness per 10ms]
+	DimmUp, DimmDown *uint8
+	// Dim rate [unit 1/65535 absolute brightness per 10ms]
+	DimmUp88, DimmDown88 *uint16
+	// Output mapping (like DMX)
+	OutputMap *uint8
+	// Servo limits & speed
+	ServoAdjustLow, ServoAdjustHigh, ServoSpeed *uint8
+	// Pulse time for output [unit 10ms]
+	Ticks *uint8
+	// Electrical behaviour of switch ports
+	SwitchCtrl *uint8
+	// Electrical behaviour of input ports
+	InputCtrl *uint8
+	// Color of a coloured output
+	RGB *RGB
+	// Port type configuration (flat port model only)
+	Reconfig *PortReconfig
+	// Parameters not covered by the fields above
+	Other map[uint8]uint32
+}
+
+// merge the given parameters into the configuration.
+func (c *PortConfig) merge(params []messages.LcConfigParam) {
+	for _, p := range params {
+		v8 := uint8(p.Value)
+		v16 := uint16(p.Value)
+		switch p.Enum {
+		case bidib.BIDIB_PCFG_NONE, bidib.BIDIB_PCFG_CONTINUE:
+			// No value
+		case bidib.BIDIB_PCFG_LEVEL_PORT_ON:
+			c.LevelOn = &v8
+		case bidib.BIDIB_PCFG_LEVEL_PORT_OFF:
+			c.LevelOff = &v8
+		case bidib.BIDIB_PCFG_DIMM_UP:
+			c.DimmUp = &v8
+		case bidib.BIDIB_PCFG_DIMM_DOWN:
+			c.DimmDown = &v8
+		case bidib.BIDIB_PCFG_DIMM_UP_8_8:
+			c.DimmUp88 = &v16
+		case bidib.BIDIB_PCFG_DIMM_DOWN_8_8:
+			c.DimmDown88 = &v16
+		case bidib.BIDIB_PCFG_OUTPUT_MAP:
+			c.OutputMap = &v8
+		case bidib.BIDIB_PCFG_SERVO_ADJ_L:
+			c.ServoAdjustLow = &v8
+		case bidib.BIDIB_PCFG_SERVO_ADJ_H:
+			c.ServoAdjustHigh = &v8
+		case bidib.BIDIB_PCFG_SERVO_SPEED:
+			c.ServoSpeed = &v8
+		case bidib.BIDIB_PCFG_TICKS:
+			c.Ticks = &v8
+		case bidib.BIDIB_PCFG_SWITCH_CTRL:
+			c.SwitchCtrl = &v8
+		case bidib.BIDIB_PCFG_INPUT_CTRL:
+			c.InputCtrl = &v8
+		case bidib.BIDIB_PCFG_RGB:
+			c.RGB = &RGB{R: uint8(p.Value), G: uint8(p.Value >> 8), B: uint8(p.Value >> 16)}
+		case bidib.BIDIB_PCFG_RECONFIG:
+			c.Reconfig = &PortReconfig{Type: PortType(p.Value), PortMap: uint16(p.Value >> 8)}
+		default:
+			if c.Other == nil {
+				c.Other = make(map[uint8]uint32)
+			}
+			c.Other[p.Enum] = p.Value
+		}
+	}
+}
+
+// params returns the parameters for all fields that are set.
+func (c PortConfig) params() []messages.LcConfigParam {
+	var result []messages.LcConfigParam
+	add8 := func(enum uint8, v *uint8) {
+		if v != nil {
+			result = append(result, messages.LcConfigParam{Enum: enum, Value: uint32(*v)})
+		}
+	}
+	add16 := func(enum uint8, v *uint16) {
+		if v != nil {
+			result = append(result, messages.LcConfigParam{Enum: enum, Value: uint32(*v)})
+		}
+	}
+	add8(bidib.BIDIB_PCFG_LEVEL_PORT_ON, c.LevelOn)
+	add8(bidib.BIDIB_PCFG_LEVEL_PORT_OFF, c.LevelOff)
+	add8(bidib.BIDIB_PCFG_DIMM_UP, c.DimmUp)
+	add8(bidib.BIDIB_PCFG_DIMM_DOWN, c.DimmDown)
+	add16(bidib.BIDIB_PCFG_DIMM_UP_8_8, c.DimmUp88)
+	add16(bidib.BIDIB_PCFG_DIMM_DOWN_8_8, c.DimmDown88)
+	add8(bidib.BIDIB_PCFG_OUTPUT_MAP, c.OutputMap)
+	add8(bidib.BIDIB_PCFG_SERVO_ADJ_L, c.ServoAdjustLow)
+	add8(bidib.BIDIB_PCFG_SERVO_ADJ_H, c.ServoAdjustHigh)
+	add8(bidib.BIDIB_PCFG_SERVO_SPEED, c.ServoSpeed)
+	add8(bidib.BIDIB_PCFG_TICKS, c.Ticks)
+	add8(bidib.BIDIB_PCFG_SWITCH_CTRL, c.SwitchCtrl)
+	add8(bidib.BIDIB_PCFG_INPUT_CTRL, c.InputCtrl)
+	if c.RGB != nil {
+		result = append(result, messages.LcConfigParam{
+			Enum:  bidib.BIDIB_PCFG_RGB,
+			Value: uint32(c.RGB.R) | uint32(c.RGB.G)<<8 | uint32(c.RGB.B)<<16,
+		})
+	}
+	if c.Reconfig != nil {
+		result = append(result, messages.LcConfigParam{
+			Enum:  bidib.BIDIB_PCFG_RECONFIG,
+			Value: uint32(c.Reconfig.Type) | uint32(c.Reconfig.PortMap)<<8,
+		})
+	}
+	for enum, value := range c.Other {
+		result = append(result, messages.LcConfigParam{Enum: enum, Value: value})
+	}
+	return result
+}
+
+// clone returns a copy of the configuration that does not share memory with the original.
+func (c PortConfig) clone() PortConfig {
+	var result PortConfig
+	result.merge(c.params())
+	return result
+}
@@ -0,0 +1,90 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestPortConfigPacking(t *testing.T) {
+	var cfg PortConfig
+	cfg.merge([]messages.LcConfigParam{
+		{Enum: bidib.BIDIB_PCFG_RGB, Value: 0x030201},
+		{Enum: bidib.BIDIB_PCFG_RECONFIG, Value: 0x820502},
+	})
+	require.NotNil(t, cfg.RGB)
+	assert.Equal(t, RGB{R: 1, G: 2, B: 3}, *cfg.RGB)
+	require.NotNil(t, cfg.Reconfig)
+	assert.Equal(t, PortType(2), cfg.Reconfig.Type)
+	assert.Equal(t, uint16(0x8205), cfg.Reconfig.PortMap)
+	assert.True(t, cfg.Reconfig.Supports(0))
+	assert.False(t, cfg.Reconfig.Supports(1))
+	assert.True(t, cfg.Reconfig.Supports(15))
+
+	assert.ElementsMatch(t, []messages.LcConfigParam{
+		{Enum: bidib.BIDIB_PCFG_RGB, Value: 0x030201},
+		{Enum: bidib.BIDIB_PCFG_RECONFIG, Value: 0x820502},
+	}, cfg.params())
+}
+
+func TestPortConfigClone(t *testing.T) {
+	params := []messages.LcConfigParam{
+		{Enum: bidib.BIDIB_PCFG_LEVEL_PORT_ON, Value: 200},
+		{Enum: bidib.BIDIB_PCFG_LEVEL_PORT_OFF, Value: 10},
+		{Enum: bidib.BIDIB_PCFG_DIMM_UP, Value: 1},
+		{Enum: bidib.BIDIB_PCFG_DIMM_DOWN, Value: 2},
+		{Enum: bidib.BIDIB_PCFG_DIMM_UP_8_8, Value: 0x1234},
+		{Enum: bidib.BIDIB_PCFG_DIMM_DOWN_8_8, Value: 0x4321},
+		{Enum: bidib.BIDIB_PCFG_OUTPUT_MAP, Value: 3},
+		{Enum: bidib.BIDIB_PCFG_SERVO_ADJ_L, Value: 4},
+		{Enum: bidib.BIDIB_PCFG_SERVO_ADJ_H, Value: 5},
+		{Enum: bidib.BIDIB_PCFG_SERVO_SPEED, Value: 6},
+		{Enum: bidib.BIDIB_PCFG_TICKS, Value: 7},
+		{Enum: bidib.BIDIB_PCFG_SWITCH_CTRL, Value: 8},
+		{Enum: bidib.BIDIB_PCFG_INPUT_CTRL, Value: 9},
+		{Enum: bidib.BIDIB_PCFG_RGB, Value: 0x102030},
+		{Enum: bidib.BIDIB_PCFG_RECONFIG, Value: 0x000101},
+		{Enum: 0x3E, Value: 11},
+		{Enum: 0xC1, Value: 0x12345678},
+	}
+	var cfg PortConfig
+	cfg.merge(params)
+	assert.ElementsMatch(t, params, cfg.params())
+
+	clone := cfg.clone()
+	assert.Equal(t, cfg, clone)
+	*clone.LevelOn = 1
+	clone.RGB.R = 0
+	clone.Other[0x3E] = 0
+	assert.Equal(t, uint8(200), *cfg.LevelOn)
+	assert.Equal(t, uint8(0x30), cfg.RGB.R)
+	assert.Equal(t, uint32(11), cfg.Other[0x3E])
+}
+
+func TestConfigRequestContinuation(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	nlc := newNodeLc(h.intfNode)
+	port := messages.TypedPort(uint8(PortTypeLight), 3)
+	conn.onSend = func(attempt int, m []bidib.Message) {
+		h.reply(nlc.Address, bidib.MSG_LC_CONFIGX, messages.LcConfigX{Port: port, Params: []messages.LcConfigParam{
+			{Enum: bidib.BIDIB_PCFG_LEVEL_PORT_ON, Value: 200},
+			{Enum: bidib.BIDIB_PCFG_CONTINUE},
+		}})
+		h.reply(nlc.Address, bidib.MSG_LC_CONFIGX, messages.LcConfigX{Port: port, Params: []messages.LcConfigParam{
+			{Enum: bidib.BIDIB_PCFG_DIMM_UP, Value: 5},
+		}})
+	}
+	cfg, err := nlc.ReadConfig(context.Background(), port)
+	require.NoError(t, err)
+	require.NotNil(t, cfg.LevelOn)
+	assert.Equal(t, uint8(200), *cfg.LevelOn)
+	require.NotNil(t, cfg.DimmUp)
+	assert.Equal(t, uint8(5), *cfg.DimmUp)
+	assert.Equal(t, 1, conn.sendCount())
+}
@@ -0,0 +1,337 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// MacroState is the state of a macro, as reported by MSG_LC_MACRO_STATE.
+type MacroState uint8
+
+const (
+	MacroOff      MacroState = bidib.BIDIB_MACRO_OFF
+	MacroStarted  MacroState = bidib.BIDIB_MACRO_START
+	MacroRunning  MacroState = bidib.BIDIB_MACRO_RUNNING
+	MacroRestored MacroState = bidib.BIDIB_MACRO_RESTORE
+	MacroSaved    MacroState = bidib.BIDIB_MACRO_SAVE
+	MacroDeleted  MacroState = bidib.BIDIB_MACRO_DELETE
+	MacroNotExist MacroState = bidib.BIDIB_MACRO_NOTEXIST
+)
+
+func (s MacroState) String() string {
+	switch s {
+	case MacroOff:
+		return "off"
+	case MacroStarted:
+		return "started"
+	case MacroRunning:
+		return "running"
+	case MacroRestored:
+		return "restored"
+	case MacroSaved:
+		return "saved"
+	case MacroDeleted:
+		return "deleted"
+	case MacroNotExist:
+		return "not-exist"
+	default:
+		return fmt.Sprintf("MacroState(%d)", uint8(s))
+	}
+}
+
+var (
+	// Returned when a node reports that a macro does not exist.
+	ErrMacroNotAvailable = errors.New("macro not available")
+)
+
+// MacroStep is a single step of a macro.
+type MacroStep struct {
+	// Delay before the step is executed
+	Delay uint8
+	// Port to control, or 0xFF followed by a system function (BIDIB_MSYS_*)
+	Port messages.LcPort
+	// State of the port, or parameter of the system function
+	Status uint8
+}
+
+// SystemStep returns a macro step that executes the given system function (BIDIB_MSYS_*).
+func SystemStep(delay, function, param uint8) MacroStep {
+	return MacroStep{Delay: delay, Port: messages.LcPort{0xFF, function}, Status: param}
+}
+
+// IsSystem returns true if the step executes a system function.
+func (s MacroStep) IsSystem() bool {
+	return s.Port[0] == 0xFF
+}
+
+// isEnd returns true if the step marks the end of the macro.
+func (s MacroStep) isEnd() bool {
+	return s.IsSystem() && s.Port[1] == bidib.BIDIB_MSYS_END_OF_MACRO
+}
+
+// Macro is the definition of a macro on a node.
+type Macro struct {
+	// Number of the macro
+	Number uint8
+	// Steps of the macro (without end marker)
+	Steps []MacroStep
+	// Slowdown factor (BIDIB_MACRO_PARA_SLOWDOWN)
+	Slowdown uint8
+	// Number of repetitions, 0=forever (BIDIB_MACRO_PARA_REPEAT)
+	Repeat uint8
+	// Start time in TCODE format (BIDIB_MACRO_PARA_START_CLK)
+	StartClock [4]byte
+}
+
+// MacroCount returns the number of macros of the node, as reported by FEATURE_CTRL_MAC_COUNT.
+func (nlc *NodeLc) MacroCount() uint8 {
+	count, _ := nlc.GetFeature(bidib.FEATURE_CTRL_MAC_COUNT)
+	return count
+}
+
+// MacroSize returns the maximum number of steps per macro, as reported by FEATURE_CTRL_MAC_SIZE.
+func (nlc *NodeLc) MacroSize() uint8 {
+	size, _ := nlc.GetFeature(bidib.FEATURE_CTRL_MAC_SIZE)
+	return size
+}
+
+// GetMacroState returns the last reported state of the macro with given number.
+// Returns state, found
+func (nlc *NodeLc) GetMacroState(num uint8) (MacroState, bool) {
+	nlc.stateMutex.RLock()
+	defer nlc.stateMutex.RUnlock()
+	state, found := nlc.macroStates[num]
+	return state, found
+}
+
+// ReadMacros reads the definitions of all macros of the node.
+func (nlc *NodeLc) ReadMacros(ctx context.Context) ([]Macro, error) {
+	count := nlc.MacroCount()
+	result := make([]Macro, 0, count)
+	for num := uint8(0); num < count; num++ {
+		m, err := nlc.ReadMacro(ctx, num)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// ReadMacro reads the definition of the macro with given number.
+func (nlc *NodeLc) ReadMacro(ctx context.Context, num uint8) (Macro, error) {
+	if err := nlc.checkMacroNum(num); err != nil {
+		return Macro{}, err
+	}
+	result := Macro{Number: num}
+	size := nlc.MacroSize()
+	for item := uint8(0); item < size; item++ {
+		step, err := nlc.macroStepRequest(ctx, messages.LcMacroGet{
+			BaseMessage: nlc.createBaseMessage(),
+			MacroNum:    num,
+			Item:        item,
+		})
+		if err != nil {
+			return Macro{}, err
+		}
+		if step.isEnd() {
+			break
+		}
+		result.Steps = append(result.Steps, step)
+	}
+	params := []struct {
+		idx   uint8
+		apply func([4]byte)
+	}{
+		{bidib.BIDIB_MACRO_PARA_SLOWDOWN, func(v [4]byte) { result.Slowdown = v[0] }},
+		{bidib.BIDIB_MACRO_PARA_REPEAT, func(v [4]byte) { result.Repeat = v[0] }},
+		{bidib.BIDIB_MACRO_PARA_START_CLK, func(v [4]byte) { result.StartClock = v }},
+	}
+	for _, p := range params {
+		value, err := nlc.macroParaRequest(ctx, num, p.idx, messages.LcMacroParaGet{
+			BaseMessage: nlc.createBaseMessage(),
+			MacroNum:    num,
+			ParaIdx:     p.idx,
+		})
+		if err != nil {
+			return Macro{}, err
+		}
+		p.apply(value)
+	}
+	return result, nil
+}
+
+// WriteMacro writes the given macro definition (steps & parameters) to the node.
+// The macro should not be running while it is written.
+// Use SaveMacro to store the macro permanently.
+func (nlc *NodeLc) WriteMacro(ctx context.Context, m Macro) error {
+	if err := nlc.checkMacroNum(m.Number); err != nil {
+		return err
+	}
+	steps := m.Steps
+	size := int(nlc.MacroSize())
+	if len(steps) > size {
+		return fmt.Errorf("macro %d has %d steps, node supports %d", m.Number, len(steps), size)
+	}
+	if len(steps) < size {
+		steps = append(append([]MacroStep(nil), steps...), SystemStep(0, bidib.BIDIB_MSYS_END_OF_MACRO, 0))
+	}
+	for item, step := range steps {
+		req := messages.LcMacroSet{
+			BaseMessage: nlc.createBaseMessage(),
+			LcMacroStep: messages.LcMacroStep{
+				MacroNum: m.Number,
+				Item:     uint8(item),
+				Delay:    step.Delay,
+				Port:     step.Port,
+				Status:   step.Status,
+			},
+		}
+		confirmed, err := nlc.macroStepRequest(ctx, req)
+		if err != nil {
+			return err
+		}
+		if confirmed != step {
+			return fmt.Errorf("node rejected step %d of macro %d", item, m.Number)
+		}
+	}
+	params := []struct {
+		idx   uint8
+		value [4]byte
+	}{
+		{bidib.BIDIB_MACRO_PARA_SLOWDOWN, [4]byte{m.Slowdown}},
+		{bidib.BIDIB_MACRO_PARA_REPEAT, [4]byte{m.Repeat}},
+		{bidib.BIDIB_MACRO_PARA_START_CLK, m.StartClock},
+	}
+	for _, p := range params {
+		if err := nlc.SetMacroParameter(ctx, m.Number, p.idx, p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMacroParameter writes a parameter (BIDIB_MACRO_PARA_*) of the macro with given number.
+func (nlc *NodeLc) SetMacroParameter(ctx context.Context, num, paraIdx uint8, value [4]byte) error {
+	if err := nlc.checkMacroNum(num); err != nil {
+		return err
+	}
+	_, err := nlc.macroParaRequest(ctx, num, paraIdx, messages.LcMacroParaSet{
+		BaseMessage: nlc.createBaseMessage(),
+		MacroNum:    num,
+		ParaIdx:     paraIdx,
+		Value:       value,
+	})
+	return err
+}
+
+// StartMacro starts the macro with given number.
+func (nlc *NodeLc) StartMacro(ctx context.Context, num uint8) (MacroState, error) {
+	return nlc.handleMacro(ctx, num, bidib.BIDIB_MACRO_START)
+}
+
+// StopMacro stops the macro with given number.
+func (nlc *NodeLc) StopMacro(ctx context.Context, num uint8) (MacroState, error) {
+	return nlc.handleMacro(ctx, num, bidib.BIDIB_MACRO_OFF)
+}
+
+// SaveMacro stores the macro with given number permanently on the node.
+func (nlc *NodeLc) SaveMacro(ctx context.Context, num uint8) (MacroState, error) {
+	return nlc.handleMacro(ctx, num, bidib.BIDIB_MACRO_SAVE)
+}
+
+// RestoreMacro reloads the macro with given number from the permanent storage of the node.
+func (nlc *NodeLc) RestoreMacro(ctx context.Context, num uint8) (MacroState, error) {
+	return nlc.handleMacro(ctx, num, bidib.BIDIB_MACRO_RESTORE)
+}
+
+// DeleteMacro clears the macro with given number.
+func (nlc *NodeLc) DeleteMacro(ctx context.Context, num uint8) (MacroState, error) {
+	return nlc.handleMacro(ctx, num, bidib.BIDIB_MACRO_DELETE)
+}
+
+// handleMacro sends MSG_LC_MACRO_HANDLE and waits for the resulting macro state.
+func (nlc *NodeLc) handleMacro(ctx context.Context, num, opcode uint8) (MacroState, error) {
+	if err := nlc.checkMacroNum(num); err != nil {
+		return 0, err
+	}
+	var state MacroState
+	keys := []responseKey{nlc.responseKey(bidib.MSG_LC_MACRO_STATE, uint32(num))}
+	err := nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if m, ok := m.(messages.LcMacroState); ok {
+			state = MacroState(m.State)
+			if state == MacroNotExist {
+				return true, fmt.Errorf("%w: %d", ErrMacroNotAvailable, num)
+			}
+			return true, nil
+		}
+		return false, nil
+	}, messages.LcMacroHandle{BaseMessage: nlc.createBaseMessage(), MacroNum: num, Opcode: opcode})
+	return state, err
+}
+
+// macroStepRequest sends the given message and waits for the matching MSG_LC_MACRO.
+func (nlc *NodeLc) macroStepRequest(ctx context.Context, m bidib.Message) (MacroStep, error) {
+	var num, item uint8
+	switch m := m.(type) {
+	case messages.LcMacroGet:
+		num, item = m.MacroNum, m.Item
+	case messages.LcMacroSet:
+		num, item = m.MacroNum, m.Item
+	}
+	var result MacroStep
+	keys := []responseKey{nlc.responseKey(bidib.MSG_LC_MACRO, macroItemKey(num, item))}
+	err := nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if m, ok := m.(messages.LcMacro); ok {
+			result = MacroStep{Delay: m.Delay, Port: m.Port, Status: m.Status}
+			return true, nil
+		}
+		return false, nil
+	}, m)
+	return result, err
+}
+
+// macroParaRequest sends the given message and waits for the matching MSG_LC_MACRO_PARA.
+func (nlc *NodeLc) macroParaRequest(ctx context.Context, num, paraIdx uint8, m bidib.Message) ([4]byte, error) {
+	var result [4]byte
+	keys := []responseKey{nlc.responseKey(bidib.MSG_LC_MACRO_PARA, macroItemKey(num, paraIdx))}
+	err := nlc.host.request(ctx, nlc.Node, nlc.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if m, ok := m.(messages.LcMacroPara); ok {
+			result = m.Value
+			return true, nil
+		}
+		return false, nil
+	}, m)
+	return result, err
+}
+
+// checkMacroNum returns an error if the node has no macro with given number.
+func (nlc *NodeLc) checkMacroNum(num uint8) error {
+	if count := nlc.MacroCount(); num >= count {
+		return fmt.Errorf("%w: %d (count %d)", ErrMacroNotAvailable, num, count)
+	}
+	return nil
+}
+
+// macroItemKey returns the response key for a macro step or parameter message.
+func macroItemKey(num, item uint8) uint32 {
+	return uint32(num)<<8 | uint32(item)
+}
+
+// setMacroState records the reported state of a macro.
+func (nlc *NodeLc) setMacroState(num uint8, state MacroState) {
+	nlc.stateMutex.Lock()
+	if nlc.macroStates == nil {
+		nlc.macroStates = make(map[uint8]MacroState)
+	}
+	changed := nlc.macroStates[num] != state
+	nlc.macroStates[num] = state
+	nlc.stateMutex.Unlock()
+	if changed {
+		nlc.invokeNodeChanged()
+	}
+}
@@ -0,0 +1,207 @@
+package host
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const classLc = bidib.ClassID(1)
+
+// fakeMacroNode simulates the macro storage of a node.
+type fakeMacroNode struct {
+	mutex  sync.Mutex
+	steps  map[uint8]messages.LcMacroStep
+	params map[uint8][4]byte
+	// Step items that the node changes when they are set
+	reject map[uint8]bool
+	// State reported for all macro handle requests (0 reports the opcode)
+	state uint8
+}
+
+// newFakeMacroNode creates a macro node and lets it respond to the messages
+// sent to given connection.
+func newFakeMacroNode(h *host, conn *fakeConnection) *fakeMacroNode {
+	f := &fakeMacroNode{
+		steps:  make(map[uint8]messages.LcMacroStep),
+		params: make(map[uint8][4]byte),
+		reject: make(map[uint8]bool),
+	}
+	conn.onSend = func(attempt int, msgs []bidib.Message) {
+		for _, m := range msgs {
+			f.respond(h, m)
+		}
+	}
+	return f
+}
+
+// respond to a single message.
+func (f *fakeMacroNode) respond(h *host, m bidib.Message) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	addr := bidib.InterfaceAddress()
+	switch m := m.(type) {
+	case messages.LcMacroSet:
+		step := m.LcMacroStep
+		if f.reject[step.Item] {
+			step.Status++
+		}
+		f.steps[step.Item] = step
+		h.reply(addr, bidib.MSG_LC_MACRO, messages.LcMacro{LcMacroStep: step})
+	case messages.LcMacroGet:
+		step, found := f.steps[m.Item]
+		if !found {
+			step = messages.LcMacroStep{MacroNum: m.MacroNum, Item: m.Item, Port: messages.LcPort{0xFF, bidib.BIDIB_MSYS_END_OF_MACRO}}
+		}
+		h.reply(addr, bidib.MSG_LC_MACRO, messages.LcMacro{LcMacroStep: step})
+	case messages.LcMacroParaSet:
+		f.params[m.ParaIdx] = m.Value
+		h.reply(addr, bidib.MSG_LC_MACRO_PARA, messages.LcMacroPara{MacroNum: m.MacroNum, ParaIdx: m.ParaIdx, Value: m.Value})
+	case messages.LcMacroParaGet:
+		h.reply(addr, bidib.MSG_LC_MACRO_PARA, messages.LcMacroPara{MacroNum: m.MacroNum, ParaIdx: m.ParaIdx, Value: f.params[m.ParaIdx]})
+	case messages.LcMacroHandle:
+		state := f.state
+		if state == 0 {
+			state = m.Opcode
+		}
+		h.reply(addr, bidib.MSG_LC_MACRO_STATE, messages.LcMacroState{MacroNum: m.MacroNum, State: state})
+	}
+}
+
+// storedSteps returns the number of steps stored in the node.
+func (f *fakeMacroNode) storedSteps() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.steps)
+}
+
+// storedStep returns the step with given item that is stored in the node.
+func (f *fakeMacroNode) storedStep(item uint8) MacroStep {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	step := f.steps[item]
+	return MacroStep{Delay: step.Delay, Port: step.Port, Status: step.Status}
+}
+
+// newTestMacroHost creates a host with a switching node that supports 2 macros
+// with given number of steps.
+func newTestMacroHost(t *testing.T, size uint8) (*NodeLc, *fakeMacroNode) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := setupTestNodeClass(h, classLc, map[bidib.FeatureID]uint8{
+		bidib.FEATURE_CTRL_MAC_COUNT: 2,
+		bidib.FEATURE_CTRL_MAC_SIZE:  size,
+	})
+	return n.Lc(), newFakeMacroNode(h, conn)
+}
+
+func TestWriteMacroRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         uint8
+		steps        []MacroStep
+		expectStored int
+	}{
+		{
+			name: "end marker appended",
+			size: 4,
+			steps: []MacroStep{
+				{Delay: 1, Port: messages.LcPort{0, 3}, Status: 1},
+				{Delay: 5, Port: messages.LcPort{0, 3}, Status: 0},
+			},
+			expectStored: 3,
+		},
+		{
+			name: "full macro without end marker",
+			size: 2,
+			steps: []MacroStep{
+				{Delay: 1, Port: messages.LcPort{0, 3}, Status: 1},
+				SystemStep(2, bidib.BIDIB_MSYS_DELAY_FIXED, 10),
+			},
+			expectStored: 2,
+		},
+		{
+			name:         "empty macro",
+			size:         4,
+			expectStored: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nlc, node := newTestMacroHost(t, tc.size)
+			ctx := context.Background()
+			m := Macro{
+				Number:     1,
+				Steps:      tc.steps,
+				Slowdown:   3,
+				Repeat:     2,
+				StartClock: [4]byte{1, 2, 3, 4},
+			}
+			require.NoError(t, nlc.WriteMacro(ctx, m))
+			assert.Equal(t, tc.expectStored, node.storedSteps())
+			if tc.expectStored > len(tc.steps) {
+				assert.True(t, node.storedStep(uint8(len(tc.steps))).isEnd())
+			}
+
+			read, err := nlc.ReadMacro(ctx, 1)
+			require.NoError(t, err)
+			assert.Equal(t, m, read)
+		})
+	}
+}
+
+func TestWriteMacroErrors(t *testing.T) {
+	ctx := context.Background()
+	step := MacroStep{Delay: 1, Port: messages.LcPort{0, 3}, Status: 1}
+
+	t.Run("too many steps", func(t *testing.T) {
+		nlc, node := newTestMacroHost(t, 1)
+		err := nlc.WriteMacro(ctx, Macro{Number: 0, Steps: []MacroStep{step, step}})
+		assert.Error(t, err)
+		assert.Equal(t, 0, node.storedSteps())
+	})
+	t.Run("unknown macro", func(t *testing.T) {
+		nlc, _ := newTestMacroHost(t, 4)
+		err := nlc.WriteMacro(ctx, Macro{Number: 2, Steps: []MacroStep{step}})
+		assert.ErrorIs(t, err, ErrMacroNotAvailable)
+	})
+	t.Run("rejected step", func(t *testing.T) {
+		nlc, node := newTestMacroHost(t, 4)
+		node.reject[1] = true
+		err := nlc.WriteMacro(ctx, Macro{Number: 0, Steps: []MacroStep{step, step, step}})
+		assert.EqualError(t, err, "node rejected step 1 of macro 0")
+		// No steps are written after the rejected one
+		assert.Equal(t, 2, node.storedSteps())
+	})
+}
+
+func TestHandleMacro(t *testing.T) {
+	ctx := context.Background()
+	nlc, node := newTestMacroHost(t, 4)
+
+	state, err := nlc.StartMacro(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, MacroStarted, state)
+	nlc.host.syncQueue(t)
+	state, found := nlc.GetMacroState(1)
+	assert.True(t, found)
+	assert.Equal(t, MacroStarted, state)
+
+	state, err = nlc.StopMacro(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, MacroOff, state)
+
+	node.state = bidib.BIDIB_MACRO_NOTEXIST
+	state, err = nlc.SaveMacro(ctx, 1)
+	assert.ErrorIs(t, err, ErrMacroNotAvailable)
+	assert.Equal(t, MacroNotExist, state)
+
+	_, err = nlc.DeleteMacro(ctx, 5)
+	assert.ErrorIs(t, err, ErrMacroNotAvailable)
+}
@@ -0,0 +1,243 @@
+package host
+
+import (
+	"time"
+
+	"github.com/binkynet/bidib/messages"
+)
+
+// NodeAddedEvent is the payload of a node added event.
+type NodeAddedEvent struct {
+	// The node that was added
+	Node *Node
+	// The parent of the added node
+	Parent *Node
+}
+
+// NodeRemovedEvent is the payload of a node removed event.
+type NodeRemovedEvent struct {
+	// The node that was removed
+	Node *Node
+	// The (former) parent of the removed node
+	Parent *Node
+}
+
+// processNodeTabCount starts (re)loading the node table.
+func (n *Node) processNodeTabCount(m messages.NodeTabCount) {
+	baseMsg := n.createBaseMessage()
+	n.mutex.Lock()
+	// Keep existing children, so they can be reused when they're still in the table
+	if n.table.previous == nil {
+		n.table.previous = n.table.children
+	}
+	n.table.count = m.TableLength
+	n.table.children = nil
+	n.table.ready = false
+	n.mutex.Unlock()
+	n.host.markLifecycleDirty()
+	if m.TableLength == 0 {
+		// Table does not yet exist, try again in a bit
+		n.host.postDelayedOnQueue(func() {
+			n.sendMessages(messages.NodeTabGetAll{BaseMessage: baseMsg})
+		}, time.Millisecond*20)
+	} else {
+		// Fetch next node table entry
+		n.sendMessages(messages.NodeTabGetNext{BaseMessage: baseMsg})
+	}
+	n.invokeNodeChanged()
+}
+
+// processNodeTab adds an entry to the node table that is being loaded.
+func (n *Node) processNodeTab(m messages.NodeTab) {
+	baseMsg := n.createBaseMessage()
+	var entry *Node
+	if m.NodeAddress == 0 {
+		// Got my own node
+	} else if child := n.findChild(n.table.previous, m.NodeAddress); child != nil && child.GetUniqueID() == m.UniqueID {
+		// Existing child node is unchanged
+		entry = child
+	} else {
+		// Found new child node
+		entry = n.addChild(m.NodeAddress)
+	}
+	n.mutex.Lock()
+	n.table.version = m.TableVersion
+	n.table.children = append(n.table.children, entry)
+	ready := len(n.table.children) >= int(n.table.count)
+	var removed []*Node
+	if ready {
+		n.table.ready = true
+		// Collect all previous children that are no longer in the table
+		for _, old := range n.table.previous {
+			if old != nil && n.findChild(n.table.children, old.Address.Last()) != old {
+				removed = append(removed, old)
+			}
+		}
+		n.table.previous = nil
+	}
+	n.mutex.Unlock()
+	n.host.markLifecycleDirty()
+	for _, old := range removed {
+		n.invokeSubtreeRemoved(old)
+	}
+	if !ready {
+		// Fetch next node table entry
+		n.sendMessages(messages.NodeTabGetNext{BaseMessage: baseMsg})
+	}
+	n.invokeNodeChanged()
+}
+
+// processNodeNew adds a single node to the node table.
+func (n *Node) processNodeNew(m messages.NodeNew) {
+	if !n.acceptTableVersion(m.TableVersion) {
+		return
+	}
+	if child := n.findChild(n.table.children, m.NodeAddress); child != nil {
+		if child.GetUniqueID() == m.UniqueID {
+			// We already know this node
+			return
+		}
+		// Another node took the address
+		n.removeChild(child)
+	}
+	child := n.addChild(m.NodeAddress)
+	n.mutex.Lock()
+	n.table.children = append(n.table.children, child)
+	n.table.count = uint8(len(n.table.children))
+	n.mutex.Unlock()
+	n.host.markLifecycleDirty()
+	n.invokeNodeChanged()
+}
+
+// processNodeLost removes a single node from the node table.
+func (n *Node) processNodeLost(m messages.NodeLost) {
+	if !n.acceptTableVersion(m.TableVersion) {
+		return
+	}
+	if child := n.findChild(n.table.children, m.NodeAddress); child != nil {
+		n.log.Warn().
+			Str("child", child.Address.String()).
+			Str("node", child.Description()).
+			Msg("Node lost")
+		n.removeChild(child)
+		n.invokeNodeChanged()
+	}
+}
+
+// processNodeNa handles a report that a child node is not available.
+func (n *Node) processNodeNa(m messages.NodeNa) {
+	if child := n.findChild(n.table.children, m.NodeAddress); child != nil {
+		n.log.Warn().
+			Str("child", child.Address.String()).
+			Msg("Node not available")
+		n.removeChild(child)
+		n.invokeNodeChanged()
+	}
+}
+
+// acceptTableVersion acknowledges a change of the node table.
+// If one or more changes were missed, the entire node table is reloaded
+// and false is returned.
+func (n *Node) acceptTableVersion(version uint8) bool {
+	baseMsg := n.createBaseMessage()
+	expected := n.table.version + 1
+	if expected == 0 {
+		// Table version 0 is skipped on wrap around
+		expected = 1
+	}
+	if !n.table.ready || version != expected {
+		// We missed a change, reload the entire table (this implicitly acknowledges the change)
+		n.log.Info().
+			Uint8("version", version).
+			Uint8("expected", expected).
+			Msg("Node table changes missed, reloading")
+		n.sendMessages(messages.NodeTabGetAll{BaseMessage: baseMsg})
+		return false
+	}
+	n.mutex.Lock()
+	n.table.version = version
+	n.mutex.Unlock()
+	n.sendMessages(messages.NodeChangedAck{BaseMessage: baseMsg, VersionNumber: version})
+	return true
+}
+
+// visibleChildren returns the children of the node that are visible to readers.
+// While the node table is being reloaded, the last complete table is returned.
+// The node mutex must be held by the caller.
+func (n *Node) visibleChildren() []*Node {
+	if !n.table.ready && n.table.previous != nil {
+		return n.table.previous
+	}
+	return n.table.children
+}
+
+// routingChildren returns the children of the node that uplink messages
+// are routed to.
+// While the node table is being reloaded, this contains the children that
+// are already (re)loaded, followed by the children of the previous table
+// whose address has not been reloaded yet.
+// The node mutex must be held by the caller.
+func (n *Node) routingChildren() []*Node {
+	if n.table.ready || n.table.previous == nil {
+		return n.table.children
+	}
+	result := append([]*Node(nil), n.table.children...)
+	for _, old := range n.table.previous {
+		if old != nil && n.findChild(n.table.children, old.Address.Last()) == nil {
+			result = append(result, old)
+		}
+	}
+	return result
+}
+
+// findChild returns the child with given local address from the given list.
+// Returns nil if not found.
+func (n *Node) findChild(list []*Node, localAddr uint8) *Node {
+	for _, child := range list {
+		if child != nil && child.Address.Last() == localAddr {
+			return child
+		}
+	}
+	return nil
+}
+
+// addChild creates a new child node with given local address and starts
+// reading its properties.
+func (n *Node) addChild(localAddr uint8) *Node {
+	child := newNode(n.Address.Append(localAddr), n.host, n.conn, n.host.log)
+	// Fetch basic info for child node
+	child.readNodeProperties()
+	n.host.invokeNodeAdded(NodeAddedEvent{Node: child, Parent: n})
+	return child
+}
+
+// removeChild removes the given child from the node table.
+func (n *Node) removeChild(child *Node) {
+	n.mutex.Lock()
+	children := make([]*Node, 0, len(n.table.children))
+	for _, x := range n.table.children {
+		if x != child {
+			children = append(children, x)
+		}
+	}
+	n.table.children = children
+	n.table.count = uint8(len(children))
+	n.mutex.Unlock()
+	n.host.markLifecycleDirty()
+	n.invokeSubtreeRemoved(child)
+}
+
+// invokeSubtreeRemoved emits a node removed event for the given (former) child
+// and all of its descendants, depth-first, so descendants are reported before
+// their parent.
+func (n *Node) invokeSubtreeRemoved(child *Node) {
+	child.mutex.Lock()
+	grandChildren := append([]*Node(nil), child.visibleChildren()...)
+	child.mutex.Unlock()
+	for _, x := range grandChildren {
+		if x != nil {
+			child.invokeSubtreeRemoved(x)
+		}
+	}
+	n.host.invokeNodeRemoved(NodeRemovedEvent{Node: child, Parent: n})
+}
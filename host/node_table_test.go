@@ -0,0 +1,159 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// loadNodeTable replies a complete node table (with given version and child addresses)
+// for the node with given address.
+func loadNodeTable(t *testing.T, h *host, addr bidib.Address, version uint8, children ...uint8) {
+	h.reply(addr, bidib.MSG_NODETAB_COUNT, messages.NodeTabCount{TableLength: uint8(len(children) + 1)})
+	h.reply(addr, bidib.MSG_NODETAB, messages.NodeTab{TableVersion: version})
+	for _, child := range children {
+		h.reply(addr, bidib.MSG_NODETAB, messages.NodeTab{
+			TableVersion: version,
+			NodeAddress:  child,
+			UniqueID:     bidib.UniqueID{0, 0, 13, 0x78, 0x56, child, 0},
+		})
+	}
+	h.syncQueue(t)
+}
+
+// subscribeNodeTree returns a channel that receives all node added & removed events.
+func subscribeNodeTree(t *testing.T, h *host) <-chan HostEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return h.Subscribe(ctx, SubscriptionFilter{
+		Kinds: []EventKind{EventKindNodeAdded, EventKindNodeRemoved},
+	})
+}
+
+// nextEvent returns the next event from the given channel.
+func nextEvent(t *testing.T, ch <-chan HostEvent) HostEvent {
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for event")
+		return HostEvent{}
+	}
+}
+
+// countSent returns the number of sent messages for which match returns true.
+func countSent(conn *fakeConnection, match func(bidib.Message) bool) int {
+	count := 0
+	for _, m := range conn.sentMessages() {
+		if match(m) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestNodeNew(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	intf := bidib.InterfaceAddress()
+	loadNodeTable(t, h, intf, 1)
+	events := subscribeNodeTree(t, h)
+
+	h.reply(intf, bidib.MSG_NODE_NEW, messages.NodeNew{TableVersion: 2, NodeAddress: 3})
+	h.syncQueue(t)
+
+	e := nextEvent(t, events)
+	require.Equal(t, EventKindNodeAdded, e.Kind)
+	assert.Equal(t, bidib.MustNewAddress(3), e.NodeAdded.Node.Address)
+	assert.Equal(t, h.intfNode, e.NodeAdded.Parent)
+	_, found := h.GetNode(bidib.MustNewAddress(3))
+	assert.True(t, found)
+	assert.Equal(t, 1, countSent(conn, func(m bidib.Message) bool {
+		ack, ok := m.(messages.NodeChangedAck)
+		return ok && ack.VersionNumber == 2
+	}))
+}
+
+func TestNodeTableVersionMismatch(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	intf := bidib.InterfaceAddress()
+	loadNodeTable(t, h, intf, 1, 1)
+
+	// Version 2 was missed
+	h.reply(intf, bidib.MSG_NODE_NEW, messages.NodeNew{TableVersion: 3, NodeAddress: 3})
+	h.reply(intf, bidib.MSG_NODE_LOST, messages.NodeLost{TableVersion: 3, NodeAddress: 1})
+	h.syncQueue(t)
+
+	_, found := h.GetNode(bidib.MustNewAddress(3))
+	assert.False(t, found)
+	_, found = h.GetNode(bidib.MustNewAddress(1))
+	assert.True(t, found)
+	assert.Equal(t, 0, countSent(conn, func(m bidib.Message) bool {
+		_, ok := m.(messages.NodeChangedAck)
+		return ok
+	}))
+	assert.Equal(t, 2, countSent(conn, func(m bidib.Message) bool {
+		getAll, ok := m.(messages.NodeTabGetAll)
+		return ok && getAll.Address == intf
+	}))
+}
+
+func TestNodeLostRemovesSubtree(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	intf := bidib.InterfaceAddress()
+	loadNodeTable(t, h, intf, 1, 1)
+	loadNodeTable(t, h, bidib.MustNewAddress(1), 1, 5)
+	events := subscribeNodeTree(t, h)
+
+	h.reply(intf, bidib.MSG_NODE_LOST, messages.NodeLost{TableVersion: 2, NodeAddress: 1})
+	h.syncQueue(t)
+
+	// Descendants are reported before their parent
+	e := nextEvent(t, events)
+	require.Equal(t, EventKindNodeRemoved, e.Kind)
+	assert.Equal(t, bidib.MustNewAddress(1, 5), e.NodeRemoved.Node.Address)
+	assert.Equal(t, bidib.MustNewAddress(1), e.NodeRemoved.Parent.Address)
+	e = nextEvent(t, events)
+	require.Equal(t, EventKindNodeRemoved, e.Kind)
+	assert.Equal(t, bidib.MustNewAddress(1), e.NodeRemoved.Node.Address)
+	assert.Equal(t, h.intfNode, e.NodeRemoved.Parent)
+
+	_, found := h.GetNode(bidib.MustNewAddress(1))
+	assert.False(t, found)
+	assert.Equal(t, 1, countSent(conn, func(m bidib.Message) bool {
+		ack, ok := m.(messages.NodeChangedAck)
+		return ok && ack.VersionNumber == 2
+	}))
+}
+
+func TestNodeNa(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	intf := bidib.InterfaceAddress()
+	loadNodeTable(t, h, intf, 1, 1, 2)
+	events := subscribeNodeTree(t, h)
+
+	h.reply(intf, bidib.MSG_NODE_NA, messages.NodeNa{NodeAddress: 2})
+	h.syncQueue(t)
+
+	e := nextEvent(t, events)
+	require.Equal(t, EventKindNodeRemoved, e.Kind)
+	assert.Equal(t, bidib.MustNewAddress(2), e.NodeRemoved.Node.Address)
+	_, found := h.GetNode(bidib.MustNewAddress(2))
+	assert.False(t, found)
+	_, found = h.GetNode(bidib.MustNewAddress(1))
+	assert.True(t, found)
+	// Not a table change, so nothing is acknowledged
+	assert.Equal(t, 0, countSent(conn, func(m bidib.Message) bool {
+		_, ok := m.(messages.NodeChangedAck)
+		return ok
+	}))
+}
@@ -0,0 +1,74 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestSetFeature(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := h.intfNode
+	conn.onSend = func(attempt int, m []bidib.Message) {
+		if fs, ok := m[0].(messages.FeatureSet); ok {
+			h.reply(n.Address, bidib.MSG_FEATURE, messages.Feature{Feature: fs.Feature, Value: fs.Value})
+		}
+	}
+
+	// Rejected while the feature list is being fetched
+	n.features.mutex.Lock()
+	n.features.loading = true
+	n.features.mutex.Unlock()
+	_, err := n.SetFeature(context.Background(), bidib.FEATURE_BM_SECACK_ON, 20)
+	assert.ErrorIs(t, err, ErrFeaturesLoading)
+	assert.Equal(t, 0, conn.sendCount())
+
+	n.features.mutex.Lock()
+	n.features.loading = false
+	n.features.mutex.Unlock()
+	value, err := n.SetFeature(context.Background(), bidib.FEATURE_BM_SECACK_ON, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(20), value)
+	cached, _ := n.GetFeature(bidib.FEATURE_BM_SECACK_ON)
+	assert.Equal(t, uint8(20), cached)
+	assert.Equal(t, 1, conn.sendCount())
+}
+
+func TestIdentify(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := h.intfNode
+	// The node confirms every identify request
+	conn.onSend = func(attempt int, msgs []bidib.Message) {
+		for _, m := range msgs {
+			if m, ok := m.(messages.SysIdentify); ok {
+				h.reply(n.Address, bidib.MSG_SYS_IDENTIFY_STATE, messages.SysIdentityState{Value: m.Value})
+			}
+		}
+	}
+	events := make(chan IdentifyEvent, 4)
+	h.RegisterIdentifyChanged(func(e IdentifyEvent) { events <- e })
+	assert.False(t, n.IsIdentifying())
+
+	require.NoError(t, n.Identify(true))
+	e := receive(t, events)
+	assert.Same(t, n, e.Node)
+	assert.True(t, e.Identifying)
+	assert.True(t, n.IsIdentifying())
+	assert.Equal(t, messages.SysIdentify{Value: true}, conn.lastSent())
+
+	// Unchanged state is not reported again
+	h.reply(n.Address, bidib.MSG_SYS_IDENTIFY_STATE, messages.SysIdentityState{Value: true})
+
+	require.NoError(t, n.Identify(false))
+	e = receive(t, events)
+	assert.False(t, e.Identifying)
+	assert.False(t, n.IsIdentifying())
+	assert.Empty(t, events)
+}
@@ -0,0 +1,157 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const (
+	// Time used to disable vendor mode when closing a session.
+	vendorDisableTimeout = time.Second
+)
+
+var (
+	// Returned when using a vendor session that has been closed.
+	ErrVendorSessionClosed = errors.New("vendor session is closed")
+)
+
+// VendorValue is a value of a vendor specific configuration variable.
+type VendorValue string
+
+// String returns the raw value.
+func (v VendorValue) String() string {
+	return string(v)
+}
+
+// Int returns the value as an integer.
+func (v VendorValue) Int() (int, error) {
+	return strconv.Atoi(string(v))
+}
+
+// Bool returns the value as a boolean ("0" is false, any other number is true).
+func (v VendorValue) Bool() (bool, error) {
+	i, err := v.Int()
+	return i != 0, err
+}
+
+// VendorSession is an open vendor configuration (user config mode) session on a node.
+// The session must be closed, to bring the node back into normal operation.
+type VendorSession struct {
+	node   *Node
+	mutex  sync.Mutex
+	closed bool
+}
+
+// VendorSession puts the node in vendor configuration mode.
+// The caller must close the returned session when done.
+func (n *Node) VendorSession(ctx context.Context) (*VendorSession, error) {
+	vs := &VendorSession{node: n}
+	if err := vs.setEnabled(ctx, true); err != nil {
+		// Make sure the node does not stay in vendor mode
+		vs.Close()
+		return nil, err
+	}
+	return vs, nil
+}
+
+// WithVendorSession opens a vendor session, calls the given function with it
+// and closes the session afterwards.
+func (n *Node) WithVendorSession(ctx context.Context, cb func(*VendorSession) error) error {
+	vs, err := n.VendorSession(ctx)
+	if err != nil {
+		return err
+	}
+	cbErr := cb(vs)
+	if err := vs.Close(); err != nil && cbErr == nil {
+		return err
+	}
+	return cbErr
+}
+
+// Get reads the vendor specific variable with given name.
+func (vs *VendorSession) Get(ctx context.Context, name string) (VendorValue, error) {
+	return vs.request(ctx, name, messages.VendorGet{
+		BaseMessage: vs.node.createBaseMessage(),
+		Name:        name,
+	})
+}
+
+// Set writes the vendor specific variable with given name.
+// Returns the value as confirmed by the node.
+func (vs *VendorSession) Set(ctx context.Context, name string, value string) (VendorValue, error) {
+	return vs.request(ctx, name, messages.VendorSet{
+		BaseMessage: vs.node.createBaseMessage(),
+		Name:        name,
+		Value:       value,
+	})
+}
+
+// SetInt writes the vendor specific variable with given name to an integer value.
+// Returns the value as confirmed by the node.
+func (vs *VendorSession) SetInt(ctx context.Context, name string, value int) (VendorValue, error) {
+	return vs.Set(ctx, name, strconv.Itoa(value))
+}
+
+// Close disables vendor configuration mode on the node.
+// The node is always instructed to leave vendor mode, even if the session
+// had errors or is already closed.
+func (vs *VendorSession) Close() error {
+	vs.mutex.Lock()
+	vs.closed = true
+	vs.mutex.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), vendorDisableTimeout)
+	defer cancel()
+	return vs.setEnabled(ctx, false)
+}
+
+// request sends the given vendor get/set message and waits for the matching vendor message.
+func (vs *VendorSession) request(ctx context.Context, name string, m bidib.Message) (VendorValue, error) {
+	vs.mutex.Lock()
+	closed := vs.closed
+	vs.mutex.Unlock()
+	if closed {
+		return "", ErrVendorSessionClosed
+	}
+	var result VendorValue
+	n := vs.node
+	keys := []responseKey{n.responseKey(bidib.MSG_VENDOR, 0)}
+	err := n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if vm, ok := m.(messages.Vendor); ok && vm.Name == name {
+			result = VendorValue(vm.Value)
+			return true, nil
+		}
+		return false, nil
+	}, m)
+	if err != nil {
+		return "", fmt.Errorf("vendor variable %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// setEnabled sends a vendor enable/disable message and waits for the acknowledgement.
+func (vs *VendorSession) setEnabled(ctx context.Context, enable bool) error {
+	n := vs.node
+	var m bidib.Message
+	if enable {
+		m = messages.VendorEnable{BaseMessage: n.createBaseMessage(), UniqueID: n.GetUniqueID()}
+	} else {
+		m = messages.VendorDisable{BaseMessage: n.createBaseMessage()}
+	}
+	keys := []responseKey{n.responseKey(bidib.MSG_VENDOR_ACK, 0)}
+	return n.host.request(ctx, n, n.host.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+		if ack, ok := m.(messages.VendorAck); ok && ack.Changed != enable {
+			if enable {
+				return true, fmt.Errorf("node refused vendor mode")
+			}
+			return true, fmt.Errorf("node did not leave vendor mode")
+		}
+		return true, nil
+	}, m)
+}
@@ -0,0 +1,135 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// fakeVendorNode simulates the vendor configuration mode of a node.
+type fakeVendorNode struct {
+	mutex     sync.Mutex
+	variables map[string]string
+	enabled   bool
+	// If set, the node does not enter vendor mode
+	refuse bool
+	// If set, the node does not answer get & set requests
+	silent   bool
+	disables int
+}
+
+// newFakeVendorNode creates a vendor node and lets it respond to the messages
+// sent to given connection.
+func newFakeVendorNode(h *host, conn *fakeConnection) *fakeVendorNode {
+	f := &fakeVendorNode{variables: map[string]string{"addr": "3"}}
+	conn.onSend = func(attempt int, msgs []bidib.Message) {
+		for _, m := range msgs {
+			f.respond(h, m)
+		}
+	}
+	return f
+}
+
+// respond to a single message.
+func (f *fakeVendorNode) respond(h *host, m bidib.Message) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	addr := bidib.InterfaceAddress()
+	switch m := m.(type) {
+	case messages.VendorEnable:
+		f.enabled = !f.refuse
+		h.reply(addr, bidib.MSG_VENDOR_ACK, messages.VendorAck{Changed: f.enabled})
+	case messages.VendorDisable:
+		f.enabled = false
+		f.disables++
+		h.reply(addr, bidib.MSG_VENDOR_ACK, messages.VendorAck{Changed: false})
+	case messages.VendorGet:
+		if !f.silent {
+			h.reply(addr, bidib.MSG_VENDOR, messages.Vendor{Name: m.Name, Value: f.variables[m.Name]})
+		}
+	case messages.VendorSet:
+		if !f.silent {
+			f.variables[m.Name] = m.Value
+			h.reply(addr, bidib.MSG_VENDOR, messages.Vendor{Name: m.Name, Value: m.Value})
+		}
+	}
+}
+
+// state returns the vendor mode and the number of disable requests.
+func (f *fakeVendorNode) state() (bool, int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.enabled, f.disables
+}
+
+func TestVendorSession(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	node := newFakeVendorNode(h, conn)
+	ctx := context.Background()
+
+	vs, err := h.intfNode.VendorSession(ctx)
+	require.NoError(t, err)
+	enabled, _ := node.state()
+	assert.True(t, enabled)
+
+	value, err := vs.Get(ctx, "addr")
+	require.NoError(t, err)
+	assert.Equal(t, VendorValue("3"), value)
+	value, err = vs.Set(ctx, "name", "gbm")
+	require.NoError(t, err)
+	assert.Equal(t, VendorValue("gbm"), value)
+	value, err = vs.SetInt(ctx, "addr", 5)
+	require.NoError(t, err)
+	i, err := value.Int()
+	require.NoError(t, err)
+	assert.Equal(t, 5, i)
+
+	require.NoError(t, vs.Close())
+	enabled, disables := node.state()
+	assert.False(t, enabled)
+	assert.Equal(t, 1, disables)
+	_, err = vs.Get(ctx, "addr")
+	assert.ErrorIs(t, err, ErrVendorSessionClosed)
+}
+
+func TestVendorSessionRefused(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	node := newFakeVendorNode(h, conn)
+	node.refuse = true
+
+	vs, err := h.intfNode.VendorSession(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, vs)
+	// The node is told to leave vendor mode anyway
+	_, disables := node.state()
+	assert.Equal(t, 1, disables)
+}
+
+func TestWithVendorSessionDisablesAfterError(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	node := newFakeVendorNode(h, conn)
+	errCallback := errors.New("callback failed")
+
+	err := h.intfNode.WithVendorSession(context.Background(), func(vs *VendorSession) error {
+		node.mutex.Lock()
+		node.silent = true
+		node.mutex.Unlock()
+		_, err := vs.Get(context.Background(), "addr")
+		assert.ErrorIs(t, err, ErrNoResponse)
+		return errCallback
+	})
+	assert.ErrorIs(t, err, errCallback)
+	enabled, disables := node.state()
+	assert.False(t, enabled)
+	assert.Equal(t, 1, disables)
+}
@@ -16,6 +16,7 @@ type HostMessage interface {
 // uplinkMessage is send into the host message queue when a messages was received from the interface.
 type uplinkMessage struct {
 	Addr    bidib.Address
+	Type    bidib.MessageType
 	Message bidib.Message
 	Num     bidib.SequenceNumber
 }
@@ -28,9 +29,13 @@ const (
 // callbackMessage is a function that is placed on the host message queue to run
 // code in the context of the message queue.
 type callbackMessage struct {
-	Callback func()
+	Callback func(ctx context.Context)
 }
 
+// queueContextKey is the key of the context value that marks code running in the
+// message queue.
+type queueContextKey struct{}
+
 // Parse the given message and put into the message queue.
 func (h *host) parseAndQueue(mType bidib.MessageType, addr bidib.Address, seqNum bidib.SequenceNumber, data []byte) {
 	log := h.log.With().
@@ -44,6 +49,7 @@ func (h *host) parseAndQueue(mType bidib.MessageType, addr bidib.Address, seqNum
 	}
 	if err := h.enqueueMessage(uplinkMessage{
 		Addr:    addr,
+		Type:    mType,
 		Message: pm,
 		Num:     seqNum,
 	}, uplinkMessageTimeout); err != nil {
@@ -54,6 +60,13 @@ func (h *host) parseAndQueue(mType bidib.MessageType, addr bidib.Address, seqNum
 
 // postOnQueue posts the given function to be called in the context of the message queue.
 func (h *host) postOnQueue(cb func(), timeout ...time.Duration) error {
+	return h.postOnQueueContext(func(context.Context) { cb() }, timeout...)
+}
+
+// postOnQueueContext posts the given function to be called in the context of the message queue.
+// The function is passed a context that is marked as running in the message queue,
+// which must be used for any request made by the function.
+func (h *host) postOnQueueContext(cb func(ctx context.Context), timeout ...time.Duration) error {
 	t := defaultPostOnQueueTimeout
 	if len(timeout) > 0 {
 		t = timeout[0]
@@ -64,10 +77,22 @@ func (h *host) postOnQueue(cb func(), timeout ...time.Duration) error {
 // postDelayedOnQueue waits (async) for a given delay, before posting the given message
 // on the message queue.
 func (h *host) postDelayedOnQueue(cb func(), delay time.Duration, timeout ...time.Duration) {
-	go func() {
-		time.Sleep(delay)
-		h.postOnQueue(cb, timeout...)
-	}()
+	ctx := h.queueCtx
+	h.goRun(func() {
+		select {
+		case <-time.After(delay):
+			h.postOnQueue(cb, timeout...)
+		case <-ctx.Done():
+			// Host is closing
+		}
+	})
+}
+
+// isOnQueue returns true if the given context is marked as running in the
+// message queue of this host.
+func (h *host) isOnQueue(ctx context.Context) bool {
+	owner, _ := ctx.Value(queueContextKey{}).(*host)
+	return owner == h
 }
 
 // Post the given message onto the message queue
@@ -85,8 +110,10 @@ func (h *host) enqueueMessage(msg HostMessage, timeout time.Duration) error {
 }
 
 // Process messages from the message queue
+// The message queue channel is never closed, since other goroutines may still
+// try to post on it.
 func (h *host) runMessageQueue(ctx context.Context) {
-	defer close(h.messageQueue)
+	queueCtx := context.WithValue(ctx, queueContextKey{}, h)
 	for {
 		select {
 		case <-ctx.Done():
@@ -97,8 +124,9 @@ func (h *host) runMessageQueue(ctx context.Context) {
 			case uplinkMessage:
 				h.processUplinkMessage(msg)
 			case callbackMessage:
-				msg.Callback()
+				msg.Callback(queueCtx)
 			}
+			h.publishSnapshot()
 		}
 	}
 }
@@ -111,7 +139,7 @@ func (h *host) processUplinkMessage(msg uplinkMessage) {
 		Logger()
 	// Find node that for the address
 	addr := msg.Addr
-	node, found := h.GetNode(addr)
+	node, found := h.routeNode(addr)
 	if !found {
 		log.Warn().
 			Str("addr", addr.String()).
@@ -119,6 +147,14 @@ func (h *host) processUplinkMessage(msg uplinkMessage) {
 		return
 	}
 
+	// Check sequence number
+	if !node.trackUplink(msg.Num) {
+		log.Debug().
+			Str("addr", addr.String()).
+			Msg("ignoring duplicate message")
+		return
+	}
+
 	// Let node process message
 	pm := msg.Message
 	if err := node.processMessage(pm); err != nil {
@@ -127,7 +163,14 @@ func (h *host) processUplinkMessage(msg uplinkMessage) {
 			Interface("msg", pm).
 			Msg("failed to process message for node")
 	}
-	if _, ok := pm.(messages.SysError); ok {
+	// Pass message to requests waiting for it
+	h.dispatchResponse(addr, msg.Type, pm)
+	// Pass message to subscribers
+	h.publish(HostEvent{Kind: EventKindUplinkMessage, Node: node, MessageType: msg.Type, Message: pm})
+	if se, ok := pm.(messages.SysError); ok {
+		if se.Error == bidib.BIDIB_ERR_SEQUENCE {
+			node.processSequenceError(se)
+		}
 		log.Warn().
 			Str("msg", pm.String()).
 			Msg("Received error from node")
@@ -151,4 +194,7 @@ func (h *host) processUplinkMessage(msg uplinkMessage) {
 			h.invokeNodeChanged(NodeEvent{Node: h.intfNode})
 		}
 	}
+
+	// Update lifecycle state
+	h.updateLifecycle()
 }
@@ -0,0 +1,223 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const (
+	defaultRequestTimeout = time.Millisecond * 250
+	defaultRequestRetries = 2
+)
+
+var (
+	// Returned when a node did not respond to a request in time.
+	ErrNoResponse = errors.New("no response from node")
+	// Returned when a node does not support a requested feature.
+	ErrFeatureNotAvailable = errors.New("feature not available")
+	// Returned when a feature is queried or changed while the features of a node are being fetched.
+	ErrFeaturesLoading = errors.New("features are being loaded")
+	// Returned when a request is made in the context of the message queue,
+	// which would block the queue that has to deliver the response.
+	ErrCalledOnQueue = errors.New("request cannot be made from the message queue")
+)
+
+// responseKey identifies a response message a request is waiting for.
+type responseKey struct {
+	addr  bidib.Address
+	mType bidib.MessageType
+	key   uint32
+}
+
+// requestOptions control the timing of a single request.
+type requestOptions struct {
+	// Time to wait for a response before resending the request
+	timeout time.Duration
+	// Number of times the request is resend
+	retries int
+}
+
+// pendingRequest is a request that is waiting for a response.
+type pendingRequest struct {
+	keys []responseKey
+	// handle is called (in the context of the message queue) for every
+	// response matching one of the keys.
+	// Returns true when the request is complete.
+	handle func(bidib.Message) (bool, error)
+	done   chan error
+}
+
+// pendingRequests holds all requests waiting for a response.
+type pendingRequests struct {
+	mutex sync.Mutex
+	all   map[responseKey][]*pendingRequest
+}
+
+// add registers the given request for all its keys.
+func (pr *pendingRequests) add(req *pendingRequest) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	if pr.all == nil {
+		pr.all = make(map[responseKey][]*pendingRequest)
+	}
+	for _, k := range req.keys {
+		pr.all[k] = append(pr.all[k], req)
+	}
+}
+
+// remove unregisters the given request for all its keys.
+func (pr *pendingRequests) remove(req *pendingRequest) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	for _, k := range req.keys {
+		list := pr.all[k]
+		for i, x := range list {
+			if x == req {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(pr.all, k)
+		} else {
+			pr.all[k] = list
+		}
+	}
+}
+
+// get returns a copy of the list of requests waiting for given key.
+func (pr *pendingRequests) get(k responseKey) []*pendingRequest {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	return append([]*pendingRequest(nil), pr.all[k]...)
+}
+
+// responseKeyOf returns the key used to correlate the given response message with a request.
+func responseKeyOf(m bidib.Message) uint32 {
+	switch m := m.(type) {
+	case messages.Feature:
+		return uint32(m.Feature)
+	case messages.FeatureNa:
+		return uint32(m.Feature)
+	case messages.SysPong:
+		return uint32(m.Value)
+	case messages.String:
+		return stringKey(m.Namespace, m.StringID)
+	case messages.AccessoryState:
+		return uint32(m.ANum)
+	case messages.AccessoryPara:
+		return accessoryParaKey(m.ANum, m.RequestedParaNum())
+	case messages.LcStat:
+		return lcPortKey(m.Port)
+	case messages.LcNa:
+		return lcPortKey(m.Port)
+	case messages.LcWait:
+		return lcPortKey(m.Port)
+	case messages.LcConfigX:
+		return lcPortKey(m.Port)
+	case messages.LcMacroState:
+		return uint32(m.MacroNum)
+	case messages.LcMacro:
+		return macroItemKey(m.MacroNum, m.Item)
+	case messages.LcMacroPara:
+		return macroItemKey(m.MacroNum, m.ParaIdx)
+	default:
+		return 0
+	}
+}
+
+// stringKey returns the response key for a string message.
+func stringKey(namespace, id uint8) uint32 {
+	return uint32(namespace)<<8 | uint32(id)
+}
+
+// dispatchResponse passes the given message to all requests waiting for it.
+// This function is to be called by the message loop.
+func (h *host) dispatchResponse(addr bidib.Address, mType bidib.MessageType, m bidib.Message) {
+	k := responseKey{addr: addr, mType: mType, key: responseKeyOf(m)}
+	for _, req := range h.requests.get(k) {
+		if done, err := req.handle(m); done {
+			h.requests.remove(req)
+			select {
+			case req.done <- err:
+			default:
+			}
+		}
+	}
+}
+
+// defaultRequestOptions returns the request options derived from the host config.
+func (h *host) defaultRequestOptions() requestOptions {
+	opts := requestOptions{
+		timeout: h.RequestTimeout,
+		retries: h.RequestRetries,
+	}
+	if opts.timeout <= 0 {
+		opts.timeout = defaultRequestTimeout
+	}
+	if opts.retries == 0 {
+		opts.retries = defaultRequestRetries
+	} else if opts.retries < 0 {
+		opts.retries = 0
+	}
+	return opts
+}
+
+// request sends the given messages to the node and waits until handle reports
+// that a response matching one of the given keys completed the request.
+// The messages are resend when no response is received in time.
+// This function must not be called in the context of the message queue;
+// code running there must pass the context it got from postOnQueueContext,
+// so such a mistake is reported instead of blocking the queue.
+func (h *host) request(ctx context.Context, n *Node, opts requestOptions, keys []responseKey, handle func(bidib.Message) (bool, error), m ...bidib.Message) error {
+	if h.isOnQueue(ctx) {
+		return ErrCalledOnQueue
+	}
+	req := &pendingRequest{
+		keys:   keys,
+		handle: handle,
+		done:   make(chan error, 1),
+	}
+	h.requests.add(req)
+	defer h.requests.remove(req)
+
+	for attempt := 0; attempt <= opts.retries; attempt++ {
+		if attempt > 0 {
+			n.log.Debug().Int("attempt", attempt).Msg("Resending request")
+		}
+		if err := h.postOnQueue(func() {
+			if err := n.sendMessages(m...); err != nil {
+				// Fail the request right away, instead of waiting for a response
+				select {
+				case req.done <- err:
+				default:
+				}
+			}
+		}); err != nil {
+			return err
+		}
+		timer := time.NewTimer(opts.timeout)
+		select {
+		case err := <-req.done:
+			timer.Stop()
+			return err
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// Try again
+		}
+	}
+	return fmt.Errorf("%w (addr=%s)", ErrNoResponse, n.Address)
+}
+
+// responseKey returns a key for a response of given type from this node.
+func (n *Node) responseKey(mType bidib.MessageType, key uint32) responseKey {
+	return responseKey{addr: n.Address, mType: mType, key: key}
+}
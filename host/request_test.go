@@ -0,0 +1,155 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestRequest(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	pong := func(h *host, value uint8) {
+		h.reply(bidib.InterfaceAddress(), bidib.MSG_SYS_PONG, messages.SysPong{Value: value})
+	}
+	tests := []struct {
+		name string
+		// Values of the pong messages the request waits for
+		keys []uint8
+		// Called for every attempt to send the request
+		respond func(h *host, attempt int)
+		// Error returned by the handler
+		handleErr error
+		// Cancel the context after this delay (0 means never)
+		cancelAfter time.Duration
+		expectErr   error
+		expectSends int
+	}{
+		{
+			name:        "matching response",
+			keys:        []uint8{1},
+			respond:     func(h *host, attempt int) { pong(h, 1) },
+			expectSends: 1,
+		},
+		{
+			name:        "other response is ignored",
+			keys:        []uint8{1},
+			respond:     func(h *host, attempt int) { pong(h, 2) },
+			expectErr:   ErrNoResponse,
+			expectSends: 3,
+		},
+		{
+			name: "response after retry",
+			keys: []uint8{1},
+			respond: func(h *host, attempt int) {
+				if attempt == 2 {
+					pong(h, 1)
+				}
+			},
+			expectSends: 2,
+		},
+		{
+			name:        "no response",
+			keys:        []uint8{1},
+			respond:     func(h *host, attempt int) {},
+			expectErr:   ErrNoResponse,
+			expectSends: 3,
+		},
+		{
+			name:        "context canceled",
+			keys:        []uint8{1},
+			respond:     func(h *host, attempt int) {},
+			cancelAfter: time.Millisecond * 5,
+			expectErr:   context.Canceled,
+			expectSends: 1,
+		},
+		{
+			name: "multiple keys",
+			keys: []uint8{1, 2},
+			respond: func(h *host, attempt int) {
+				pong(h, 2)
+				pong(h, 1)
+			},
+			expectSends: 1,
+		},
+		{
+			name:        "handler error",
+			keys:        []uint8{1},
+			respond:     func(h *host, attempt int) { pong(h, 1) },
+			handleErr:   errHandler,
+			expectErr:   errHandler,
+			expectSends: 1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := &fakeConnection{}
+			h := newTestHost(t, conn)
+			conn.onSend = func(attempt int, m []bidib.Message) { tc.respond(h, attempt) }
+			n := h.intfNode
+
+			ctx := context.Background()
+			if tc.cancelAfter > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(ctx)
+				time.AfterFunc(tc.cancelAfter, cancel)
+			}
+			var keys []responseKey
+			remaining := make(map[uint8]bool)
+			for _, k := range tc.keys {
+				keys = append(keys, n.responseKey(bidib.MSG_SYS_PONG, uint32(k)))
+				remaining[k] = true
+			}
+			err := h.request(ctx, n, h.defaultRequestOptions(), keys, func(m bidib.Message) (bool, error) {
+				delete(remaining, m.(messages.SysPong).Value)
+				return len(remaining) == 0, tc.handleErr
+			}, messages.SysPing{BaseMessage: n.createBaseMessage(), Value: tc.keys[0]})
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Empty(t, remaining)
+			}
+			assert.Equal(t, tc.expectSends, conn.sendCount())
+		})
+	}
+}
+
+func TestRequestOnQueue(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	n := h.intfNode
+	result := make(chan error, 1)
+	require.NoError(t, h.postOnQueueContext(func(ctx context.Context) {
+		result <- h.request(ctx, n, h.defaultRequestOptions(), nil, func(bidib.Message) (bool, error) {
+			return true, nil
+		}, messages.SysPing{BaseMessage: n.createBaseMessage()})
+	}))
+	select {
+	case err := <-result:
+		assert.ErrorIs(t, err, ErrCalledOnQueue)
+	case <-time.After(time.Second):
+		t.Fatal("request on the message queue did not return")
+	}
+}
+
+func TestRequestStallQueueFull(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	h.StallQueueLimit = 1
+	n := h.intfNode
+	n.setStalled(true)
+	keys := []responseKey{n.responseKey(bidib.MSG_SYS_PONG, 0)}
+	base := n.createBaseMessage()
+	err := h.request(context.Background(), n, h.defaultRequestOptions(), keys, func(bidib.Message) (bool, error) {
+		return true, nil
+	}, messages.SysPing{BaseMessage: base}, messages.SysPing{BaseMessage: base})
+	assert.ErrorIs(t, err, ErrStallQueueFull)
+	assert.Equal(t, 0, conn.sendCount())
+	assert.Equal(t, 2, n.StallInfo().Dropped)
+}
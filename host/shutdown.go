@@ -0,0 +1,223 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const (
+	// Time used by Close to shutdown the host
+	closeTimeout = time.Second
+	// Interval used to check for held messages while flushing
+	flushPollInterval = time.Millisecond * 10
+)
+
+var (
+	// Returned by Shutdown when messages held for stalled nodes could not be delivered in time.
+	ErrMessagesNotDelivered = errors.New("messages held for stalled nodes not delivered")
+)
+
+// CsShutdownAction defines what happens to command stations on shutdown.
+type CsShutdownAction uint8
+
+const (
+	// Leave command stations in their current state
+	CsShutdownNone CsShutdownAction = iota
+	// Put command stations in STOP state (all locos stop, track powered)
+	CsShutdownStop
+	// Put command stations in OFF state (no DCC signal)
+	CsShutdownOff
+)
+
+// ShutdownPolicy defines how the layout is left behind on shutdown.
+type ShutdownPolicy struct {
+	// Action for all command stations
+	CommandStations CsShutdownAction
+	// If set, all boosters are switched off
+	BoostersOff bool
+}
+
+// Shutdown brings the layout in a safe state (according to the given policy),
+// disables the interface, flushes pending messages and waits for all host
+// goroutines to exit.
+// If the given context expires, remaining steps are performed without waiting.
+// Returns ErrMessagesNotDelivered if messages (e.g. the safe state commands) are
+// still held for stalled nodes when the context expires.
+func (h *host) Shutdown(ctx context.Context, policy ShutdownPolicy) error {
+	h.shutdownOnce.Do(func() {
+		h.shutdownErr = h.shutdown(ctx, &policy)
+	})
+	return h.shutdownErr
+}
+
+// Close any connections.
+// Unlike Shutdown, the layout & the interface are left as they are.
+func (h *host) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	h.shutdownOnce.Do(func() {
+		h.shutdownErr = h.shutdown(ctx, nil)
+	})
+	return h.shutdownErr
+}
+
+// shutdown implements Shutdown & Close.
+// If policy is nil, the layout is left alone and the interface is not disabled.
+func (h *host) shutdown(ctx context.Context, policy *ShutdownPolicy) error {
+	log := h.log
+	var flushErr error
+	if h.intfNode != nil && !h.IsClosed() {
+		if policy != nil {
+			// Bring layout in a safe state & disable the interface
+			done := make(chan struct{})
+			if err := h.postOnQueue(func() {
+				defer close(done)
+				h.shutdownLayout(*policy)
+				atomic.StoreInt32(&h.disabledState, disabledStateDisabled)
+				h.intfNode.sendMessages(messages.SysDisable{})
+			}); err != nil {
+				log.Warn().Err(err).Msg("Failed to post shutdown on queue")
+				close(done)
+			}
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+		}
+		// Wait for stalled nodes to accept the messages held for them
+		flushErr = h.flushHeldMessages(ctx)
+	}
+
+	// Stop accepting new messages & stop goroutines
+	atomic.AddUint32(&h.closed, 1)
+	h.setState(HostStateClosed)
+	if cancel := h.cancelQueue; cancel != nil {
+		cancel()
+	}
+	h.waitForGoroutines(ctx)
+
+	// Close the connection
+	var err error
+	if conn := h.conn; conn != nil {
+		h.conn = nil
+		err = conn.Close()
+	}
+
+	// Stop delivering events
+	h.closeEvents(ctx)
+	return errors.Join(flushErr, err)
+}
+
+// shutdownLayout applies the given policy to all command stations & boosters.
+// This function is to be called by the message loop.
+func (h *host) shutdownLayout(policy ShutdownPolicy) {
+	var visit func(*Node)
+	visit = func(n *Node) {
+		if cs := n.extensions.cs; cs != nil {
+			switch policy.CommandStations {
+			case CsShutdownStop:
+				cs.desiredCsState = bidib.BIDIB_CS_STATE_STOP
+				cs.sendMessages(messages.CsSetState{BaseMessage: cs.createBaseMessage(), State: bidib.BIDIB_CS_STATE_STOP})
+			case CsShutdownOff:
+				cs.desiredCsState = bidib.BIDIB_CS_STATE_OFF
+				cs.sendMessages(messages.CsSetState{BaseMessage: cs.createBaseMessage(), State: bidib.BIDIB_CS_STATE_OFF})
+			}
+		}
+		if bst := n.extensions.bst; bst != nil && policy.BoostersOff {
+			bst.sendMessages(messages.BoostOff{BaseMessage: bst.createBaseMessage()})
+		}
+		for _, child := range n.table.children {
+			if child != nil {
+				visit(child)
+			}
+		}
+	}
+	visit(h.intfNode)
+}
+
+// flushHeldMessages waits until no more messages are held for stalled nodes,
+// or the given context expires.
+// Returns an error naming the nodes for which messages are still held
+// when the context expires.
+func (h *host) flushHeldMessages(ctx context.Context) error {
+	for {
+		held := 0
+		var stalled []string
+		h.intfNode.forEachNodeRecursive(func(n *Node) {
+			if depth := n.StallInfo().QueueDepth; depth > 0 {
+				held += depth
+				stalled = append(stalled, n.Address.String())
+			}
+		})
+		if held == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(flushPollInterval):
+		case <-ctx.Done():
+			h.log.Warn().Int("held", held).Strs("nodes", stalled).Msg("Messages held for stalled nodes are not delivered")
+			return fmt.Errorf("%w (nodes=%s)", ErrMessagesNotDelivered, strings.Join(stalled, ", "))
+		}
+	}
+}
+
+// waitForGoroutines waits until all host goroutines have exited, or the given context expires.
+func (h *host) waitForGoroutines(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		h.goroutines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		h.log.Warn().Msg("Not all host goroutines exited in time")
+	}
+}
+
+// closeEvents stops the delivery of events to all handlers & subscriptions.
+func (h *host) closeEvents(ctx context.Context) {
+	closers := []func(context.Context){
+		h.nodeChangedEvent.closeAll,
+		h.nodeAddedEvent.closeAll,
+		h.nodeRemovedEvent.closeAll,
+		h.dynStateEvent.closeAll,
+		h.bmAddressEvent.closeAll,
+		h.occupancyEvent.closeAll,
+		h.accessoryEvent.closeAll,
+		h.bstStateEvent.closeAll,
+		h.pomResultEvent.closeAll,
+		h.identifyEvent.closeAll,
+		h.healthEvent.closeAll,
+		h.linkQualityEvent.closeAll,
+		h.stateEvent.closeAll,
+		h.allEvents.closeAll,
+	}
+	for _, c := range closers {
+		c(ctx)
+	}
+}
+
+// goRun runs the given function in a goroutine that is tracked for shutdown.
+func (h *host) goRun(f func()) {
+	h.goroutines.Add(1)
+	go func() {
+		defer h.goroutines.Done()
+		f()
+	}()
+}
+
+// forEachNodeRecursive calls the given function for this node and all its (grand)children.
+func (n *Node) forEachNodeRecursive(cb func(*Node)) {
+	cb(n)
+	n.ForEachChild(func(child *Node) {
+		child.forEachNodeRecursive(cb)
+	})
+}
@@ -0,0 +1,63 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestCloseLeavesInterfaceEnabled(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	require.NoError(t, h.Close())
+	assert.Equal(t, 0, conn.sendCount())
+	assert.ErrorIs(t, h.postOnQueue(func() {}), ErrClosed)
+}
+
+func TestShutdownDisablesInterface(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	require.NoError(t, h.Shutdown(context.Background(), ShutdownPolicy{}))
+	assert.Equal(t, messages.SysDisable{}, conn.lastSent())
+}
+
+func TestShutdownWaitsForStalledNode(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := h.intfNode
+	n.setStalled(true)
+	go func() {
+		// Clear the stall once MSG_SYS_DISABLE is held
+		for n.StallInfo().QueueDepth == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		h.reply(n.Address, bidib.MSG_STALL, messages.Stall{Status: 0})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, h.Shutdown(ctx, ShutdownPolicy{}))
+	assert.Equal(t, messages.SysDisable{}, conn.lastSent())
+	assert.Equal(t, 0, n.StallInfo().QueueDepth)
+}
+
+func TestShutdownReportsUndeliveredMessages(t *testing.T) {
+	conn := &fakeConnection{}
+	h := newTestHost(t, conn)
+	n := h.intfNode
+	n.setStalled(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err := h.Shutdown(ctx, ShutdownPolicy{})
+	assert.ErrorIs(t, err, ErrMessagesNotDelivered)
+	assert.ErrorContains(t, err, n.Address.String())
+	assert.Equal(t, 1, n.StallInfo().QueueDepth)
+	assert.Equal(t, 0, conn.sendCount())
+}
@@ -0,0 +1,201 @@
+package host
+
+import (
+	"sync/atomic"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// NodeSnapshot is an immutable copy of the state of a node.
+type NodeSnapshot struct {
+	// The node this snapshot was taken from
+	Node *Node
+	// Address of the node
+	Address bidib.Address
+	// Unique ID of the node
+	UniqueID bidib.UniqueID
+	// Fingerprint of the node
+	FingerPrint uint32
+	// Magic of the node
+	Magic uint16
+	// Protocol version reported by the node
+	ProtocolVersion bidib.ProtocolVersion
+	// Software versions reported by the node (node first, then subsystems)
+	SoftwareVersions []messages.VersionTriple
+	// Node strings
+	ProductName, UserName string
+	// Feature values
+	Features map[bidib.FeatureID]uint8
+	// Set if the node is identifying itself
+	Identifying bool
+	// Liveness of the node
+	Health HealthState
+	// Direct child nodes
+	Children []*NodeSnapshot
+	// Commandstation state (nil if the node has no DCC signal generator)
+	Cs *CsSnapshot
+	// Booster state (nil if the node has no booster)
+	Bst *BstSnapshot
+	// Occupancy state (nil if the node has no occupancy detectors)
+	Bm *BmSnapshot
+	// Accessory states (nil if the node has no accessory control functions)
+	Accessories []Accessory
+	// Port states (nil if the node has no switching functions)
+	Ports []Port
+}
+
+// CsSnapshot is an immutable copy of the commandstation state of a node.
+type CsSnapshot struct {
+	State bidib.CsState
+}
+
+// BstSnapshot is an immutable copy of the booster state of a node.
+type BstSnapshot struct {
+	State       bidib.BstState
+	Current     bidib.Current
+	Voltage     bidib.Voltage
+	Temperature bidib.Temperature
+}
+
+// BmSnapshot is an immutable copy of the occupancy detector state of a node.
+type BmSnapshot struct {
+	Detectors  []BmDetector
+	Confidence BmConfidence
+}
+
+// GetFeature returns the value of the feature with given id.
+// Returns value, found
+func (ns *NodeSnapshot) GetFeature(feature bidib.FeatureID) (uint8, bool) {
+	value, found := ns.Features[feature]
+	return value, found
+}
+
+// TreeSnapshot is an immutable copy of the entire node tree.
+type TreeSnapshot struct {
+	// Incremented for every published snapshot
+	Version uint64
+	// Interface node
+	Root *NodeSnapshot
+	// All nodes by address
+	Nodes map[bidib.Address]*NodeSnapshot
+}
+
+// GetNode returns the snapshot of the node with given address.
+// Returns nil, false if not found.
+func (ts *TreeSnapshot) GetNode(addr bidib.Address) (*NodeSnapshot, bool) {
+	if ts == nil {
+		return nil, false
+	}
+	ns, found := ts.Nodes[addr]
+	return ns, found
+}
+
+// ForEach calls the given function for all nodes in the tree (depth first).
+func (ts *TreeSnapshot) ForEach(cb func(*NodeSnapshot)) {
+	var visit func(*NodeSnapshot)
+	visit = func(ns *NodeSnapshot) {
+		cb(ns)
+		for _, child := range ns.Children {
+			visit(child)
+		}
+	}
+	if ts != nil && ts.Root != nil {
+		visit(ts.Root)
+	}
+}
+
+// snapshot builds an immutable copy of the node and all its children,
+// adding them to the given map.
+func (n *Node) snapshot(all map[bidib.Address]*NodeSnapshot) *NodeSnapshot {
+	n.mutex.RLock()
+	ns := &NodeSnapshot{
+		Node:        n,
+		Address:     n.Address,
+		UniqueID:    n.UniqueID,
+		FingerPrint: n.FingerPrint,
+		Magic:       n.Magic,
+
+		ProtocolVersion:  n.versions.protocol,
+		SoftwareVersions: append([]messages.VersionTriple(nil), n.versions.software...),
+	}
+	children := n.visibleChildren()
+	ext := n.extensions
+	n.mutex.RUnlock()
+	cs, bst, bm, acc, lc := ext.cs, ext.bst, ext.bm, ext.acc, ext.lc
+
+	ns.ProductName = n.ProductName()
+	ns.UserName = n.UserName()
+	ns.Identifying = n.IsIdentifying()
+	ns.Health = n.Health()
+	n.features.mutex.RLock()
+	ns.Features = make(map[bidib.FeatureID]uint8, len(n.features.all))
+	for k, v := range n.features.all {
+		ns.Features[k] = v
+	}
+	n.features.mutex.RUnlock()
+	if cs != nil {
+		ns.Cs = &CsSnapshot{State: cs.GetState()}
+	}
+	if bst != nil {
+		bst.stateMutex.RLock()
+		ns.Bst = &BstSnapshot{
+			State:       bst.actualBstState,
+			Current:     bst.actualBstDiag.Current,
+			Voltage:     bst.actualBstDiag.Voltage,
+			Temperature: bst.actualBstDiag.Temperature,
+		}
+		bst.stateMutex.RUnlock()
+	}
+	if bm != nil {
+		ns.Bm = &BmSnapshot{
+			Detectors:  bm.Detectors(),
+			Confidence: bm.GetConfidence(),
+		}
+	}
+	if acc != nil {
+		ns.Accessories = acc.Accessories()
+	}
+	if lc != nil {
+		ns.Ports = lc.Ports()
+	}
+	for _, child := range children {
+		if child != nil {
+			ns.Children = append(ns.Children, child.snapshot(all))
+		}
+	}
+	all[ns.Address] = ns
+	return ns
+}
+
+// Snapshot returns the last published snapshot of the node tree.
+// The snapshot is updated after every change of the tree.
+func (h *host) Snapshot() *TreeSnapshot {
+	return h.snapshot.Load()
+}
+
+// markSnapshotDirty records that the node tree has changed and a new snapshot
+// must be published.
+func (h *host) markSnapshotDirty() {
+	atomic.StoreUint32(&h.snapshotDirty, 1)
+}
+
+// publishSnapshot builds & publishes a new snapshot if the tree has changed.
+func (h *host) publishSnapshot() {
+	h.snapshotMutex.Lock()
+	defer h.snapshotMutex.Unlock()
+	if atomic.SwapUint32(&h.snapshotDirty, 0) == 0 || h.intfNode == nil {
+		return
+	}
+	var version uint64
+	if last := h.snapshot.Load(); last != nil {
+		version = last.Version + 1
+	}
+	all := make(map[bidib.Address]*NodeSnapshot)
+	root := h.intfNode.snapshot(all)
+	h.snapshot.Store(&TreeSnapshot{
+		Version: version,
+		Root:    root,
+		Nodes:   all,
+	})
+}
@@ -0,0 +1,55 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// syncSnapshot waits until the snapshot is published for all messages queued so far.
+func (h *host) syncSnapshot(t *testing.T) {
+	h.syncQueue(t)
+	// The snapshot is published after the callback of syncQueue has returned
+	h.syncQueue(t)
+}
+
+func TestSnapshot(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	intf := bidib.InterfaceAddress()
+	assert.Nil(t, h.Snapshot())
+
+	h.reply(intf, bidib.MSG_SYS_MAGIC, messages.SysMagic{Magic: 0xAFFE})
+	h.reply(intf, bidib.MSG_FEATURE, messages.Feature{Feature: bidib.FEATURE_BM_SIZE, Value: 8})
+	h.syncSnapshot(t)
+	first := h.Snapshot()
+	require.NotNil(t, first)
+	assert.Equal(t, uint16(0xAFFE), first.Root.Magic)
+
+	// Nothing changed, so nothing is published
+	h.syncSnapshot(t)
+	assert.Same(t, first, h.Snapshot())
+
+	// Change the tree
+	h.reply(intf, bidib.MSG_FEATURE, messages.Feature{Feature: bidib.FEATURE_BM_SIZE, Value: 16})
+	loadNodeTable(t, h, intf, 1, 1)
+	h.syncSnapshot(t)
+	second := h.Snapshot()
+	require.NotNil(t, second)
+	assert.Greater(t, second.Version, first.Version)
+	_, found := second.GetNode(bidib.MustNewAddress(1))
+	assert.True(t, found)
+	value, _ := second.Root.GetFeature(bidib.FEATURE_BM_SIZE)
+	assert.Equal(t, uint8(16), value)
+
+	// The first snapshot is unchanged
+	_, found = first.GetNode(bidib.MustNewAddress(1))
+	assert.False(t, found)
+	assert.Empty(t, first.Root.Children)
+	assert.Len(t, first.Nodes, 1)
+	value, _ = first.Root.GetFeature(bidib.FEATURE_BM_SIZE)
+	assert.Equal(t, uint8(8), value)
+}
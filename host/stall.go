@@ -0,0 +1,171 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+const (
+	defaultStallQueueLimit = 256
+)
+
+var (
+	// Returned when a message cannot be held because the queue of a stalled node is full.
+	ErrStallQueueFull = errors.New("stall queue is full")
+)
+
+// StallInfo describes the flow control state of a node.
+type StallInfo struct {
+	// Set while the node reports that it is stalled
+	Stalled bool
+	// Time the node reported the (last) stall
+	Since time.Time
+	// Duration of the current (or last) stall
+	Duration time.Duration
+	// Number of messages held for this node and its children
+	QueueDepth int
+	// Number of times the node reported a stall
+	Count int
+	// Number of messages dropped because the stall queue was full
+	Dropped int
+}
+
+// heldMessages is a set of messages for a node that is held while
+// the node (or one of its parents) is stalled.
+type heldMessages struct {
+	node     *Node
+	messages []bidib.Message
+}
+
+// nodeStall holds the flow control state of a node.
+// Guarded by host.sendMutex.
+type nodeStall struct {
+	stalled bool
+	since   time.Time
+	until   time.Time
+	count   int
+	queue   []heldMessages
+	depth   int
+	dropped int
+}
+
+// StallInfo returns the flow control state of the node.
+func (n *Node) StallInfo() StallInfo {
+	n.host.sendMutex.Lock()
+	defer n.host.sendMutex.Unlock()
+	info := StallInfo{
+		Stalled:    n.stall.stalled,
+		Since:      n.stall.since,
+		QueueDepth: n.stall.depth,
+		Count:      n.stall.count,
+		Dropped:    n.stall.dropped,
+	}
+	if n.stall.stalled {
+		info.Duration = time.Since(n.stall.since)
+	} else if !n.stall.since.IsZero() {
+		info.Duration = n.stall.until.Sub(n.stall.since)
+	}
+	return info
+}
+
+// stallQueueLimit returns the maximum number of messages held for a stalled node.
+func (h *host) stallQueueLimit() int {
+	if h.StallQueueLimit > 0 {
+		return h.StallQueueLimit
+	}
+	return defaultStallQueueLimit
+}
+
+// stalledParent returns the node closest to the root of the tree that
+// contains the given node (or is the given node) and is stalled.
+// Returns nil if no such node exists.
+// The send mutex must be held by the caller.
+func (h *host) stalledParent(n *Node) *Node {
+	for addr := bidib.InterfaceAddress(); ; {
+		node, found := h.routeNode(addr)
+		if !found {
+			return nil
+		}
+		if node.stall.stalled {
+			return node
+		}
+		if addr.Equals(n.Address) || addr.GetLength() >= n.Address.GetLength() {
+			return nil
+		}
+		addr = addr.Append(n.Address[addr.GetLength()])
+	}
+}
+
+// sendOrHold sends the given messages to the node, or holds them when
+// the node or one of its parents is stalled.
+func (h *host) sendOrHold(n *Node, m []bidib.Message) error {
+	h.sendMutex.Lock()
+	defer h.sendMutex.Unlock()
+	if stalled := h.stalledParent(n); stalled != nil {
+		return stalled.hold(n, m)
+	}
+	return n.transmit(m)
+}
+
+// hold adds the given messages (for given target node) to the stall queue of this node.
+// The send mutex must be held by the caller.
+func (n *Node) hold(target *Node, m []bidib.Message) error {
+	if n.stall.depth+len(m) > n.host.stallQueueLimit() {
+		n.stall.dropped += len(m)
+		return fmt.Errorf("%w (addr=%s, depth=%d)", ErrStallQueueFull, n.Address, n.stall.depth)
+	}
+	n.stall.queue = append(n.stall.queue, heldMessages{node: target, messages: m})
+	n.stall.depth += len(m)
+	return nil
+}
+
+// setStalled updates the stall state of the node.
+// When the stall is cleared, all held messages are released.
+func (n *Node) setStalled(stalled bool) {
+	h := n.host
+	h.sendMutex.Lock()
+	if n.stall.stalled == stalled {
+		h.sendMutex.Unlock()
+		return
+	}
+	n.stall.stalled = stalled
+	if stalled {
+		n.stall.since = time.Now()
+		n.stall.count++
+		h.sendMutex.Unlock()
+		n.log.Debug().Msg("Node stalled")
+	} else {
+		n.stall.until = time.Now()
+		queue := n.stall.queue
+		depth := n.stall.depth
+		n.stall.queue = nil
+		n.stall.depth = 0
+		var err error
+		for _, held := range queue {
+			// Messages may still have to be held by another stalled node
+			if stalled := h.stalledParent(held.node); stalled != nil {
+				err = stalled.hold(held.node, held.messages)
+			} else {
+				err = held.node.transmit(held.messages)
+			}
+			if err != nil {
+				n.log.Warn().Err(err).Str("target", held.node.Address.String()).Msg("Failed to release held messages")
+			}
+		}
+		h.sendMutex.Unlock()
+		n.log.Debug().
+			Dur("duration", n.stall.until.Sub(n.stall.since)).
+			Int("released", depth).
+			Msg("Node stall cleared")
+	}
+	n.invokeNodeChanged()
+}
+
+// processStall handles a stall message from the node.
+func (n *Node) processStall(m messages.Stall) {
+	n.setStalled(m.Status != 0)
+}
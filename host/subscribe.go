@@ -0,0 +1,209 @@
+package host
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+// EventKind identifies the kind of a HostEvent.
+type EventKind uint8
+
+const (
+	EventKindNodeChanged EventKind = iota
+	EventKindNodeAdded
+	EventKindNodeRemoved
+	EventKindDynState
+	EventKindBmAddress
+	EventKindBstState
+	EventKindPomResult
+	EventKindIdentify
+	EventKindHealth
+	EventKindLinkQuality
+	// Any message received from a node
+	EventKindUplinkMessage
+	EventKindHostState
+	EventKindOccupancy
+	EventKindAccessory
+	// Events for the subscription were dropped because its queue was full.
+	// Subscribers that mirror host state should resync it (e.g. using Host.Snapshot).
+	EventKindResync
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventKindNodeChanged:
+		return "node-changed"
+	case EventKindNodeAdded:
+		return "node-added"
+	case EventKindNodeRemoved:
+		return "node-removed"
+	case EventKindDynState:
+		return "dyn-state"
+	case EventKindBmAddress:
+		return "bm-address"
+	case EventKindBstState:
+		return "bst-state"
+	case EventKindPomResult:
+		return "pom-result"
+	case EventKindIdentify:
+		return "identify"
+	case EventKindHealth:
+		return "health"
+	case EventKindLinkQuality:
+		return "link-quality"
+	case EventKindUplinkMessage:
+		return "uplink-message"
+	case EventKindHostState:
+		return "host-state"
+	case EventKindOccupancy:
+		return "occupancy"
+	case EventKindAccessory:
+		return "accessory"
+	case EventKindResync:
+		return "resync"
+	default:
+		return fmt.Sprintf("EventKind(%d)", uint8(k))
+	}
+}
+
+// HostEvent is a single event delivered to a subscription.
+// Depending on the Kind, one of the kind specific fields is set.
+type HostEvent struct {
+	Kind EventKind
+	// Node the event is about (nil if the node is not known)
+	Node *Node
+	// Address of the node the event is about
+	Address bidib.Address
+	// Type of the message that caused the event (if any)
+	MessageType bidib.MessageType
+	// Message that caused the event (if any)
+	Message bidib.Message
+
+	// Kind specific payloads
+	NodeAdded   *NodeAddedEvent
+	NodeRemoved *NodeRemovedEvent
+	PomResult   *PomResultEvent
+	Identify    *IdentifyEvent
+	Health      *HealthEvent
+	LinkQuality *LinkQualityEvent
+	State       *StateEvent
+	Occupancy   *OccupancyEvent
+	Accessory   *AccessoryEvent
+	// Number of dropped events (EventKindResync only)
+	Missed uint64
+}
+
+// SubscriptionFilter selects the events delivered to a subscription.
+// Empty fields match all events.
+type SubscriptionFilter struct {
+	// Only events about this node or its (grand)children
+	Subtree *bidib.Address
+	// Only events of these kinds
+	Kinds []EventKind
+	// Only events caused by messages of these types
+	MessageTypes []bidib.MessageType
+	// Only events concerning these DCC addresses
+	DccAddresses []uint16
+	// Delivery options.
+	// OverflowBlock is not supported, since a blocking subscriber would stall
+	// the message queue; it is treated as OverflowUnbounded.
+	Options EventOptions
+}
+
+// Matches returns true if the given event is accepted by the filter.
+// Resync events are not subject to the filter.
+func (f SubscriptionFilter) Matches(e HostEvent) bool {
+	if f.Subtree != nil && !f.Subtree.EqualsOrContains(e.Address) {
+		return false
+	}
+	if len(f.Kinds) > 0 && !contains(f.Kinds, e.Kind) {
+		return false
+	}
+	if len(f.MessageTypes) > 0 && (e.Message == nil || !contains(f.MessageTypes, e.MessageType)) {
+		return false
+	}
+	if len(f.DccAddresses) > 0 {
+		found := false
+		for _, addr := range e.dccAddresses() {
+			if contains(f.DccAddresses, addr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// dccAddresses returns the DCC addresses the event is about.
+func (e HostEvent) dccAddresses() []uint16 {
+	if e.PomResult != nil {
+		return []uint16{uint16(e.PomResult.DccAddress)}
+	}
+	switch m := e.Message.(type) {
+	case messages.CsDriveAck:
+		return []uint16{m.DccAddress}
+	case messages.CsAccessoryAck:
+		return []uint16{m.DccAddress}
+	case messages.CsPomAck:
+		return []uint16{uint16(m.DccAddress)}
+	case messages.CsDriveManual:
+		return []uint16{m.DccAddress}
+	case messages.CsDriveEvent:
+		return []uint16{m.DccAddress}
+	case messages.BmCv:
+		return []uint16{m.DccAddress}
+	case messages.BmSpeed:
+		return []uint16{m.DccAddress}
+	case messages.BmDynState:
+		return []uint16{m.DccAddress}
+	case messages.BmAddress:
+		result := make([]uint16, 0, len(m.DccAddresses))
+		for _, addr := range m.DccAddresses {
+			// Strip orientation bits
+			result = append(result, addr&0x3FFF)
+		}
+		return result
+	}
+	return nil
+}
+
+// contains returns true if the given list contains the given value.
+func contains[T comparable](list []T, value T) bool {
+	for _, x := range list {
+		if x == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns a channel that receives all events accepted by the given filter.
+// The subscription ends (and the channel is closed) when the given context is canceled.
+// When events are dropped because the subscriber is too slow (only with a dropping
+// overflow policy), an EventKindResync event is delivered before the next event.
+func (h *host) Subscribe(ctx context.Context, filter SubscriptionFilter) <-chan HostEvent {
+	opts := filter.Options
+	if opts.Overflow == OverflowBlock {
+		// Blocking would stall the message queue
+		opts.Overflow = OverflowUnbounded
+	}
+	return h.allEvents.subscribe(ctx, filter.Matches, func(missed uint64) HostEvent {
+		return HostEvent{Kind: EventKindResync, Missed: missed}
+	}, opts)
+}
+
+// publish the given event to all subscriptions.
+func (h *host) publish(e HostEvent) {
+	if e.Node == nil {
+		e.Node, _ = h.routeNode(e.Address)
+	} else {
+		e.Address = e.Node.Address
+	}
+	h.allEvents.Invoke(e)
+}
@@ -0,0 +1,119 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/binkynet/bidib"
+	"github.com/binkynet/bidib/messages"
+)
+
+func TestSubscriptionFilterMatches(t *testing.T) {
+	node1 := bidib.MustNewAddress(1)
+	node15 := bidib.MustNewAddress(1, 5)
+	node2 := bidib.MustNewAddress(2)
+	driveAck := HostEvent{
+		Kind:        EventKindUplinkMessage,
+		Address:     node15,
+		MessageType: bidib.MSG_CS_DRIVE_ACK,
+		Message:     messages.CsDriveAck{DccAddress: 3},
+	}
+	bmAddress := HostEvent{
+		Kind:        EventKindBmAddress,
+		Address:     node2,
+		MessageType: bidib.MSG_BM_ADDRESS,
+		Message:     messages.BmAddress{DccAddresses: []uint16{0x8007, 0x4009}},
+	}
+	pomResult := HostEvent{
+		Kind:      EventKindPomResult,
+		Address:   node1,
+		PomResult: &PomResultEvent{DccAddress: 11},
+	}
+	nodeChanged := HostEvent{Kind: EventKindNodeChanged, Address: node1}
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		event  HostEvent
+		expect bool
+	}{
+		{name: "empty filter", event: nodeChanged, expect: true},
+		{name: "subtree node itself", filter: SubscriptionFilter{Subtree: &node1}, event: nodeChanged, expect: true},
+		{name: "subtree grandchild", filter: SubscriptionFilter{Subtree: &node1}, event: driveAck, expect: true},
+		{name: "subtree other node", filter: SubscriptionFilter{Subtree: &node1}, event: bmAddress, expect: false},
+		{name: "kind match", filter: SubscriptionFilter{Kinds: []EventKind{EventKindNodeAdded, EventKindNodeChanged}}, event: nodeChanged, expect: true},
+		{name: "kind mismatch", filter: SubscriptionFilter{Kinds: []EventKind{EventKindNodeAdded}}, event: nodeChanged, expect: false},
+		{name: "message type match", filter: SubscriptionFilter{MessageTypes: []bidib.MessageType{bidib.MSG_CS_DRIVE_ACK}}, event: driveAck, expect: true},
+		{name: "message type mismatch", filter: SubscriptionFilter{MessageTypes: []bidib.MessageType{bidib.MSG_CS_DRIVE_ACK}}, event: bmAddress, expect: false},
+		{name: "message type without message", filter: SubscriptionFilter{MessageTypes: []bidib.MessageType{0}}, event: nodeChanged, expect: false},
+		{name: "dcc address of message", filter: SubscriptionFilter{DccAddresses: []uint16{3}}, event: driveAck, expect: true},
+		{name: "dcc address without orientation bits", filter: SubscriptionFilter{DccAddresses: []uint16{9}}, event: bmAddress, expect: true},
+		{name: "dcc address of pom result", filter: SubscriptionFilter{DccAddresses: []uint16{11}}, event: pomResult, expect: true},
+		{name: "dcc address mismatch", filter: SubscriptionFilter{DccAddresses: []uint16{4}}, event: driveAck, expect: false},
+		{name: "dcc address without address", filter: SubscriptionFilter{DccAddresses: []uint16{3}}, event: nodeChanged, expect: false},
+		{
+			name: "all fields match",
+			filter: SubscriptionFilter{
+				Subtree:      &node1,
+				Kinds:        []EventKind{EventKindUplinkMessage},
+				MessageTypes: []bidib.MessageType{bidib.MSG_CS_DRIVE_ACK},
+				DccAddresses: []uint16{3},
+			},
+			event:  driveAck,
+			expect: true,
+		},
+		{
+			name: "one field mismatch",
+			filter: SubscriptionFilter{
+				Subtree:      &node1,
+				Kinds:        []EventKind{EventKindUplinkMessage},
+				DccAddresses: []uint16{4},
+			},
+			event:  driveAck,
+			expect: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, tc.filter.Matches(tc.event))
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	intf := bidib.InterfaceAddress()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := h.Subscribe(ctx, SubscriptionFilter{
+		MessageTypes: []bidib.MessageType{bidib.MSG_SYS_PONG},
+	})
+
+	h.reply(intf, bidib.MSG_SYS_MAGIC, messages.SysMagic{Magic: 0xAFFE})
+	h.reply(intf, bidib.MSG_SYS_PONG, messages.SysPong{Value: 7})
+	e := nextEvent(t, events)
+	assert.Equal(t, EventKindUplinkMessage, e.Kind)
+	assert.Same(t, h.intfNode, e.Node)
+	assert.Equal(t, messages.SysPong{Value: 7}, e.Message)
+
+	// Subscription ends when the context is canceled
+	cancel()
+	for range events {
+	}
+}
+
+func TestSubscribeBlockDoesNotStallQueue(t *testing.T) {
+	h := newTestHost(t, &fakeConnection{})
+	intf := bidib.InterfaceAddress()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Never read from the channel
+	h.Subscribe(ctx, SubscriptionFilter{
+		Options: EventOptions{QueueSize: 1, Overflow: OverflowBlock},
+	})
+	for i := 0; i < 10; i++ {
+		h.reply(intf, bidib.MSG_SYS_PONG, messages.SysPong{Value: uint8(i)})
+	}
+	h.syncQueue(t)
+}
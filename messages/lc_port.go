@@ -0,0 +1,94 @@
+package messages
+
+import "fmt"
+
+// LcPort identifies a port of a light/switch control node.
+// In the typed port model, the first byte holds the port type and the second byte
+// the port number. In the flat port model, both bytes hold the port number (little endian).
+type LcPort [2]byte
+
+// LcPortAll is the port used in MSG_LC_NA to mark the end of a MSG_LC_PORT_QUERY_ALL response.
+var LcPortAll = LcPort{0xFF, 0xFF}
+
+// TypedPort returns the port with given type & number (typed port model).
+func TypedPort(portType, num uint8) LcPort {
+	return LcPort{portType, num}
+}
+
+// FlatPort returns the port with given number (flat port model).
+func FlatPort(num uint16) LcPort {
+	return LcPort{uint8(num), uint8(num >> 8)}
+}
+
+// Type returns the port type (typed port model).
+func (p LcPort) Type() uint8 {
+	return p[0]
+}
+
+// Number returns the port number (typed port model).
+func (p LcPort) Number() uint8 {
+	return p[1]
+}
+
+// Flat returns the port number (flat port model).
+func (p LcPort) Flat() uint16 {
+	return uint16(p[0]) | uint16(p[1])<<8
+}
+
+func (p LcPort) String() string {
+	return fmt.Sprintf("%d/%d", p[0], p[1])
+}
+
+// LcConfigParam is a single configuration parameter of a port.
+// The size of the value depends on the enum (BIDIB_PCFG_*):
+// 0x00-0x3F: 1 byte, 0x40-0x7F: 2 bytes, 0x80-0xBF: 3 bytes, 0xC0-0xFE: 4 bytes, 0xFF: none.
+type LcConfigParam struct {
+	Enum  uint8
+	Value uint32
+}
+
+// lcConfigParamSize returns the size of the value of a parameter with given enum.
+func lcConfigParamSize(enum uint8) int {
+	switch {
+	case enum == 0xFF:
+		return 0
+	case enum >= 0xC0:
+		return 4
+	case enum >= 0x80:
+		return 3
+	case enum >= 0x40:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// encodeLcConfigParams encodes the given parameters.
+func encodeLcConfigParams(params []LcConfigParam) []byte {
+	var data []byte
+	for _, p := range params {
+		data = append(data, p.Enum)
+		for i := 0; i < lcConfigParamSize(p.Enum); i++ {
+			data = append(data, uint8(p.Value>>(8*i)))
+		}
+	}
+	return data
+}
+
+// decodeLcConfigParams decodes a list of parameters.
+func decodeLcConfigParams(data []byte) ([]LcConfigParam, error) {
+	var result []LcConfigParam
+	for len(data) > 0 {
+		p := LcConfigParam{Enum: data[0]}
+		size := lcConfigParamSize(p.Enum)
+		if len(data) < 1+size {
+			return nil, fmt.Errorf("incomplete value for parameter 0x%02x", p.Enum)
+		}
+		for i := 0; i < size; i++ {
+			p.Value |= uint32(data[1+i]) << (8 * i)
+		}
+		result = append(result, p)
+		data = data[1+size:]
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/binkynet/bidib"
+)
+
+func TestLcConfigParamSize(t *testing.T) {
+	tests := []struct {
+		enum uint8
+		size int
+	}{
+		{0x00, 1},
+		{0x3F, 1},
+		{0x40, 2},
+		{0x7F, 2},
+		{0x80, 3},
+		{0xBF, 3},
+		{0xC0, 4},
+		{0xFE, 4},
+		{0xFF, 0},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.size, lcConfigParamSize(tc.enum), "enum 0x%02x", tc.enum)
+	}
+}
+
+func TestLcConfigParamsEncoding(t *testing.T) {
+	params := []LcConfigParam{
+		{Enum: 0x01, Value: 0xAB},
+		{Enum: 0x40, Value: 0x1234},
+		{Enum: 0x80, Value: 0x563412},
+		{Enum: 0xC0, Value: 0x78563412},
+		{Enum: 0xFF},
+	}
+	data := encodeLcConfigParams(params)
+	assert.Equal(t, []byte{
+		0x01, 0xAB,
+		0x40, 0x34, 0x12,
+		0x80, 0x12, 0x34, 0x56,
+		0xC0, 0x12, 0x34, 0x56, 0x78,
+		0xFF,
+	}, data)
+
+	decoded, err := decodeLcConfigParams(data)
+	require.NoError(t, err)
+	assert.Equal(t, params, decoded)
+
+	_, err = decodeLcConfigParams([]byte{0x40, 0x34})
+	assert.Error(t, err)
+}
+
+func TestLcConfigXContinuation(t *testing.T) {
+	m, err := decodeLcConfigX(bidib.Address{}, []byte{1, 2, 0x01, 0xAB, 0xFF})
+	require.NoError(t, err)
+	assert.Equal(t, LcPort{1, 2}, m.Port)
+	assert.True(t, m.HasContinuation())
+
+	m, err = decodeLcConfigX(bidib.Address{}, []byte{1, 2, 0x01, 0xAB})
+	require.NoError(t, err)
+	assert.False(t, m.HasContinuation())
+}
@@ -53,6 +53,64 @@ func Parse(mType bidib.MessageType, addr bidib.Address, seqNum bidib.SequenceNum
 	case bidib.MSG_SYS_CLOCK:
 		return decodeSysClock(addr, data)
 
+	// Occupancy downlink
+	case bidib.MSG_BM_GET_RANGE:
+		return decodeBmGetRange(addr, data)
+	case bidib.MSG_BM_ADDR_GET_RANGE:
+		return decodeBmAddrGetRange(addr, data)
+	case bidib.MSG_BM_GET_CONFIDENCE:
+		return decodeBmGetConfidence(addr, data)
+	case bidib.MSG_BM_MIRROR_OCC:
+		return decodeBmMirrorOcc(addr, data)
+	case bidib.MSG_BM_MIRROR_FREE:
+		return decodeBmMirrorFree(addr, data)
+	case bidib.MSG_BM_MIRROR_MULTIPLE:
+		return decodeBmMirrorMultiple(addr, data)
+
+	// Accessory downlink
+	case bidib.MSG_ACCESSORY_SET:
+		return decodeAccessorySet(addr, data)
+	case bidib.MSG_ACCESSORY_GET:
+		return decodeAccessoryGet(addr, data)
+	case bidib.MSG_ACCESSORY_PARA_SET:
+		return decodeAccessoryParaSet(addr, data)
+	case bidib.MSG_ACCESSORY_PARA_GET:
+		return decodeAccessoryParaGet(addr, data)
+
+	// Light/switch control downlink
+	case bidib.MSG_LC_PORT_QUERY_ALL:
+		return decodeLcPortQueryAll(addr, data)
+	case bidib.MSG_LC_OUTPUT:
+		return decodeLcOutput(addr, data)
+	case bidib.MSG_LC_PORT_QUERY:
+		return decodeLcPortQuery(addr, data)
+	case bidib.MSG_LC_CONFIGX_GET_ALL:
+		return decodeLcConfigXGetAll(addr, data)
+	case bidib.MSG_LC_CONFIGX_SET:
+		return decodeLcConfigXSet(addr, data)
+	case bidib.MSG_LC_CONFIGX_GET:
+		return decodeLcConfigXGet(addr, data)
+
+	// Macro downlink
+	case bidib.MSG_LC_MACRO_HANDLE:
+		return decodeLcMacroHandle(addr, data)
+	case bidib.MSG_LC_MACRO_SET:
+		return decodeLcMacroSet(addr, data)
+	case bidib.MSG_LC_MACRO_GET:
+		return decodeLcMacroGet(addr, data)
+	case bidib.MSG_LC_MACRO_PARA_SET:
+		return decodeLcMacroParaSet(addr, data)
+	case bidib.MSG_LC_MACRO_PARA_GET:
+		return decodeLcMacroParaGet(addr, data)
+
+	// Booster downlink
+	case bidib.MSG_BOOST_ON:
+		return decodeBoostOn(addr, data)
+	case bidib.MSG_BOOST_OFF:
+		return decodeBoostOff(addr, data)
+	case bidib.MSG_BOOST_QUERY:
+		return decodeBoostQuery(addr, data)
+
 	// Feature querying downlink
 	case bidib.MSG_FEATURE_GETALL:
 		return decodeFeatureGetAll(addr, data)
@@ -164,6 +222,16 @@ func Parse(mType bidib.MessageType, addr bidib.Address, seqNum bidib.SequenceNum
 		return decodeCsProgState(addr, data)
 
 	// Occupancy uplink
+	case bidib.MSG_BM_OCC:
+		return decodeBmOcc(addr, data)
+	case bidib.MSG_BM_FREE:
+		return decodeBmFree(addr, data)
+	case bidib.MSG_BM_MULTIPLE:
+		return decodeBmMultiple(addr, data)
+	case bidib.MSG_BM_CONFIDENCE:
+		return decodeBmConfidence(addr, data)
+	case bidib.MSG_BM_CURRENT:
+		return decodeBmCurrent(addr, data)
 	case bidib.MSG_BM_ADDRESS:
 		return decodeBmAddress(addr, data)
 	case bidib.MSG_BM_CV:
@@ -173,10 +241,37 @@ func Parse(mType bidib.MessageType, addr bidib.Address, seqNum bidib.SequenceNum
 	case bidib.MSG_BM_DYN_STATE:
 		return decodeBmDynState(addr, data)
 
+	// Accessory uplink
+	case bidib.MSG_ACCESSORY_STATE:
+		return decodeAccessoryState(addr, data)
+	case bidib.MSG_ACCESSORY_NOTIFY:
+		return decodeAccessoryNotify(addr, data)
+	case bidib.MSG_ACCESSORY_PARA:
+		return decodeAccessoryPara(addr, data)
+
+	// Light/switch control uplink
+	case bidib.MSG_LC_STAT:
+		return decodeLcStat(addr, data)
+	case bidib.MSG_LC_NA:
+		return decodeLcNa(addr, data)
+	case bidib.MSG_LC_WAIT:
+		return decodeLcWait(addr, data)
+	case bidib.MSG_LC_CONFIGX:
+		return decodeLcConfigX(addr, data)
+
+	// Macro uplink
+	case bidib.MSG_LC_MACRO_STATE:
+		return decodeLcMacroState(addr, data)
+	case bidib.MSG_LC_MACRO:
+		return decodeLcMacro(addr, data)
+	case bidib.MSG_LC_MACRO_PARA:
+		return decodeLcMacroPara(addr, data)
+
 	// Booster
 	case bidib.MSG_BOOST_STAT:
 		return decodeBstState(addr, data)
-	//case bidib.MSG_BOOST_CURRENT:
+	case bidib.MSG_BOOST_CURRENT:
+		return decodeBstCurrent(addr, data)
 	case bidib.MSG_BOOST_DIAGNOSTIC:
 		return decodeBstDiag(addr, data)
 
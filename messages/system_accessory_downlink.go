@@ -0,0 +1,119 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/binkynet/bidib"
+)
+
+// Set an accessory to the given aspect. Followed by 2 bytes: ANUM, ASPECT.
+// The node answers with MSG_ACCESSORY_STATE.
+type AccessorySet struct {
+	BaseMessage
+	ANum   uint8
+	Aspect uint8
+}
+
+func (m AccessorySet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.ANum, m.Aspect}
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_SET, m.Address, seqNum, data)
+}
+
+func (m AccessorySet) String() string {
+	return fmt.Sprintf("%T addr=%s anum=%d aspect=%d", m, m.Address, m.ANum, m.Aspect)
+}
+
+func decodeAccessorySet(addr bidib.Address, data []byte) (AccessorySet, error) {
+	var result AccessorySet
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.ANum = data[0]
+	result.Aspect = data[1]
+	return result, nil
+}
+
+// Query the state of an accessory. Followed by 1 byte: ANUM.
+// The node answers with MSG_ACCESSORY_STATE.
+type AccessoryGet struct {
+	BaseMessage
+	ANum uint8
+}
+
+func (m AccessoryGet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.ANum}
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_GET, m.Address, seqNum, data)
+}
+
+func (m AccessoryGet) String() string {
+	return fmt.Sprintf("%T addr=%s anum=%d", m, m.Address, m.ANum)
+}
+
+func decodeAccessoryGet(addr bidib.Address, data []byte) (AccessoryGet, error) {
+	var result AccessoryGet
+	if err := validateDataLength(data, 1); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.ANum = data[0]
+	return result, nil
+}
+
+// Write a parameter of an accessory. Followed by 2 bytes ANUM, PARA_NUM and the parameter data.
+// The node answers with MSG_ACCESSORY_PARA.
+type AccessoryParaSet struct {
+	BaseMessage
+	ANum    uint8
+	ParaNum uint8
+	Data    []byte
+}
+
+func (m AccessoryParaSet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.ANum, m.ParaNum}, m.Data...)
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_PARA_SET, m.Address, seqNum, data)
+}
+
+func (m AccessoryParaSet) String() string {
+	return fmt.Sprintf("%T addr=%s anum=%d para=%d data=%x", m, m.Address, m.ANum, m.ParaNum, m.Data)
+}
+
+func decodeAccessoryParaSet(addr bidib.Address, data []byte) (AccessoryParaSet, error) {
+	var result AccessoryParaSet
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.ANum = data[0]
+	result.ParaNum = data[1]
+	result.Data = append([]byte(nil), data[2:]...)
+	return result, nil
+}
+
+// Read a parameter of an accessory. Followed by 2 bytes: ANUM, PARA_NUM.
+// The node answers with MSG_ACCESSORY_PARA.
+type AccessoryParaGet struct {
+	BaseMessage
+	ANum    uint8
+	ParaNum uint8
+}
+
+func (m AccessoryParaGet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.ANum, m.ParaNum}
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_PARA_GET, m.Address, seqNum, data)
+}
+
+func (m AccessoryParaGet) String() string {
+	return fmt.Sprintf("%T addr=%s anum=%d para=%d", m, m.Address, m.ANum, m.ParaNum)
+}
+
+func decodeAccessoryParaGet(addr bidib.Address, data []byte) (AccessoryParaGet, error) {
+	var result AccessoryParaGet
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.ANum = data[0]
+	result.ParaNum = data[1]
+	return result, nil
+}
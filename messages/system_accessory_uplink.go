@@ -0,0 +1,176 @@
+package messages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binkynet/bidib"
+)
+
+// AccessoryStatus is the content of MSG_ACCESSORY_STATE and MSG_ACCESSORY_NOTIFY.
+// Followed by 5 or more bytes: ANUM, ASPECT, TOTAL, EXECUTE, WAIT, [DETAILS...]
+type AccessoryStatus struct {
+	// Number of the accessory
+	ANum uint8
+	// Current (or targeted) aspect
+	Aspect uint8
+	// Number of aspects of the accessory
+	Total uint8
+	// Execution state (BIDIB_ACC_STATE_*)
+	Execute uint8
+	// Remaining time (when waiting) or error code (on error)
+	Wait uint8
+	// Optional details
+	Details []byte
+}
+
+// IsError returns true if the accessory reports an error.
+func (s AccessoryStatus) IsError() bool {
+	return s.Execute&bidib.BIDIB_ACC_STATE_ERROR != 0
+}
+
+// IsDone returns true if the accessory reached its aspect without errors.
+func (s AccessoryStatus) IsDone() bool {
+	return !s.IsError() && s.Execute&bidib.BIDIB_ACC_STATE_WAIT == 0
+}
+
+// HasFeedback returns true if the node can verify the aspect of the accessory.
+func (s AccessoryStatus) HasFeedback() bool {
+	return s.Execute&bidib.BIDIB_ACC_STATE_NO_FB_AVAILABLE == 0
+}
+
+// ErrorCode returns the error code (BIDIB_ACC_STATE_ERROR_*) of the accessory
+// and true if more errors are present.
+// Only valid when IsError returns true.
+func (s AccessoryStatus) ErrorCode() (uint8, bool) {
+	return s.Wait &^ bidib.BIDIB_ACC_STATE_ERROR_MORE, s.Wait&bidib.BIDIB_ACC_STATE_ERROR_MORE != 0
+}
+
+// WaitDuration returns the expected remaining time until the aspect is reached.
+// Only valid when the accessory is not done and has no error.
+func (s AccessoryStatus) WaitDuration() time.Duration {
+	value := time.Duration(s.Wait & 0x7F)
+	if s.Wait&0x80 != 0 {
+		return value * time.Second
+	}
+	return value * time.Millisecond * 100
+}
+
+func (s AccessoryStatus) encode() []byte {
+	return append([]byte{s.ANum, s.Aspect, s.Total, s.Execute, s.Wait}, s.Details...)
+}
+
+func (s AccessoryStatus) String() string {
+	return fmt.Sprintf("anum=%d aspect=%d total=%d execute=%02x wait=%02x", s.ANum, s.Aspect, s.Total, s.Execute, s.Wait)
+}
+
+func decodeAccessoryStatus(data []byte) (AccessoryStatus, error) {
+	var result AccessoryStatus
+	if err := validateMinDataLength(data, 5); err != nil {
+		return result, err
+	}
+	result.ANum = data[0]
+	result.Aspect = data[1]
+	result.Total = data[2]
+	result.Execute = data[3]
+	result.Wait = data[4]
+	if len(data) > 5 {
+		result.Details = append([]byte(nil), data[5:]...)
+	}
+	return result, nil
+}
+
+// State of an accessory, send in response to MSG_ACCESSORY_SET or MSG_ACCESSORY_GET.
+type AccessoryState struct {
+	BaseMessage
+	AccessoryStatus
+}
+
+func (m AccessoryState) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_STATE, m.Address, seqNum, m.encode())
+}
+
+func (m AccessoryState) String() string {
+	return fmt.Sprintf("%T addr=%s %s", m, m.Address, m.AccessoryStatus)
+}
+
+func decodeAccessoryState(addr bidib.Address, data []byte) (AccessoryState, error) {
+	var result AccessoryState
+	status, err := decodeAccessoryStatus(data)
+	if err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.AccessoryStatus = status
+	return result, nil
+}
+
+// Spontaneous state change of an accessory, e.g. when an operation completes
+// or when the accessory was operated outside of BiDiB (FEATURE_ACCESSORY_SURVEILLED).
+type AccessoryNotify struct {
+	BaseMessage
+	AccessoryStatus
+}
+
+func (m AccessoryNotify) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_NOTIFY, m.Address, seqNum, m.encode())
+}
+
+func (m AccessoryNotify) String() string {
+	return fmt.Sprintf("%T addr=%s %s", m, m.Address, m.AccessoryStatus)
+}
+
+func decodeAccessoryNotify(addr bidib.Address, data []byte) (AccessoryNotify, error) {
+	var result AccessoryNotify
+	status, err := decodeAccessoryStatus(data)
+	if err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.AccessoryStatus = status
+	return result, nil
+}
+
+// Parameter of an accessory. Followed by 2 bytes ANUM, PARA_NUM and the parameter data.
+// If the parameter does not exist, PARA_NUM is BIDIB_ACCESSORY_PARA_NOTEXIST,
+// followed by the number of the requested parameter.
+type AccessoryPara struct {
+	BaseMessage
+	ANum    uint8
+	ParaNum uint8
+	Data    []byte
+}
+
+// RequestedParaNum returns the number of the parameter this message is a response to.
+func (m AccessoryPara) RequestedParaNum() uint8 {
+	if m.IsNotExist() {
+		return m.Data[0]
+	}
+	return m.ParaNum
+}
+
+// IsNotExist returns true if the node reports that the requested parameter does not exist.
+func (m AccessoryPara) IsNotExist() bool {
+	return m.ParaNum == bidib.BIDIB_ACCESSORY_PARA_NOTEXIST && len(m.Data) > 0
+}
+
+func (m AccessoryPara) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.ANum, m.ParaNum}, m.Data...)
+	bidib.EncodeMessage(write, bidib.MSG_ACCESSORY_PARA, m.Address, seqNum, data)
+}
+
+func (m AccessoryPara) String() string {
+	return fmt.Sprintf("%T addr=%s anum=%d para=%d data=%x", m, m.Address, m.ANum, m.ParaNum, m.Data)
+}
+
+func decodeAccessoryPara(addr bidib.Address, data []byte) (AccessoryPara, error) {
+	var result AccessoryPara
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.ANum = data[0]
+	result.ParaNum = data[1]
+	result.Data = append([]byte(nil), data[2:]...)
+	return result, nil
+}
@@ -61,3 +61,26 @@ func decodeBoostOff(addr bidib.Address, data []byte) (BoostOff, error) {
 	result.CurrentNodeOnly = data[0] != 0
 	return result, nil
 }
+
+// Query the booster state.
+// The node answers with MSG_BOOST_STAT and (since protocol version 0.10) MSG_BOOST_DIAGNOSTIC.
+type BoostQuery struct {
+	BaseMessage
+}
+
+func (m BoostQuery) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_BOOST_QUERY, m.Address, seqNum, nil)
+}
+
+func (m BoostQuery) String() string {
+	return fmt.Sprintf("%T addr=%s", m, m.Address)
+}
+
+func decodeBoostQuery(addr bidib.Address, data []byte) (BoostQuery, error) {
+	var result BoostQuery
+	if err := validateDataLength(data, 0); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	return result, nil
+}
@@ -31,6 +31,32 @@ func decodeBstState(addr bidib.Address, data []byte) (BstState, error) {
 	return result, nil
 }
 
+// Booster current (deprecated by MSG_BOOST_DIAGNOSTIC since protocol version 0.10).
+// Followed by 1 byte with the current, using the same coding as MSG_BOOST_DIAGNOSTIC.
+type BstCurrent struct {
+	BaseMessage
+	Current bidib.Current
+}
+
+func (m BstCurrent) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{uint8(m.Current)}
+	bidib.EncodeMessage(write, bidib.MSG_BOOST_CURRENT, m.Address, seqNum, data)
+}
+
+func (m BstCurrent) String() string {
+	return fmt.Sprintf("%T addr=%s current=%s", m, m.Address, m.Current)
+}
+
+func decodeBstCurrent(addr bidib.Address, data []byte) (BstCurrent, error) {
+	var result BstCurrent
+	if err := validateDataLength(data, 1); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Current = bidib.Current(data[0])
+	return result, nil
+}
+
 // Booster diagnostics
 type BstDiag struct {
 	BaseMessage
@@ -54,58 +80,19 @@ func (m BstDiag) String() string {
 	return fmt.Sprintf("%T addr=%s i=%02x v=%02x temp=%02x", m, m.Address, m.DiagI, m.DiagV, m.DiagTemp)
 }
 
-// Current in mA
-func (m BstDiag) Current() string {
-	i := m.DiagI
-	if i == 0 {
-		return "0 mA"
-	}
-	if i >= 1 && i <= 15 {
-		return fmt.Sprintf("%d mA", int(i))
-	}
-	if i >= 16 && i <= 63 {
-		return fmt.Sprintf("%d mA", int(i-12)*4)
-	}
-	if i >= 64 && i <= 127 {
-		return fmt.Sprintf("%d mA", int(i-51)*16)
-	}
-	if i >= 128 && i <= 191 {
-		return fmt.Sprintf("%d mA", int(i-108)*64)
-	}
-	if i >= 192 && i <= 250 {
-		return fmt.Sprintf("%d mA", int(i-171)*256)
-	}
-	if i >= 251 && i <= 253 {
-		return "reserved"
-	}
-	if i == 254 {
-		return "overcurrent"
-	}
-	return "unknown"
+// Current reported by the booster
+func (m BstDiag) Current() bidib.Current {
+	return bidib.Current(m.DiagI)
 }
 
-// Voltage
-func (m BstDiag) Voltage() string {
-	v := m.DiagV
-	if v >= 0 && v <= 250 {
-		return fmt.Sprintf("%d mV", int(v)*100)
-	}
-	if v >= 251 && v <= 254 {
-		return "reserved"
-	}
-	return "unknown"
+// Voltage reported by the booster
+func (m BstDiag) Voltage() bidib.Voltage {
+	return bidib.Voltage(m.DiagV)
 }
 
-// Temperature
-func (m BstDiag) Temperature() string {
-	t := m.DiagTemp
-	if t >= 0 && t <= 127 {
-		return fmt.Sprintf("%d C", int(t))
-	}
-	if t >= 128 && t <= 225 {
-		return "reserved"
-	}
-	return fmt.Sprintf("neg %d C", int(t))
+// Temperature reported by the booster
+func (m BstDiag) Temperature() bidib.Temperature {
+	return bidib.Temperature(m.DiagTemp)
 }
 
 func decodeBstDiag(addr bidib.Address, data []byte) (BstDiag, error) {
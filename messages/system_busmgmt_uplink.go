@@ -135,28 +135,30 @@ func decodeNodeNa(addr bidib.Address, data []byte) (NodeNa, error) {
 // the interface repeat it at a maximum of 16 times.
 type NodeLost struct {
 	BaseMessage
-	NodeAddress uint8
-	UniqueID    bidib.UniqueID
+	TableVersion uint8
+	NodeAddress  uint8
+	UniqueID     bidib.UniqueID
 }
 
 func (m NodeLost) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
-	data := [8]byte{m.NodeAddress}
-	copy(data[1:], m.UniqueID[:])
+	data := [9]byte{m.TableVersion, m.NodeAddress}
+	copy(data[2:], m.UniqueID[:])
 	bidib.EncodeMessage(write, bidib.MSG_NODE_LOST, m.Address, seqNum, data[:])
 }
 
 func (m NodeLost) String() string {
-	return fmt.Sprintf("%T addr=%s nodeAddr=%d uid=%s", m, m.Address, m.NodeAddress, m.UniqueID)
+	return fmt.Sprintf("%T addr=%s tableVersion=%d nodeAddr=%d uid=%s", m, m.Address, m.TableVersion, m.NodeAddress, m.UniqueID)
 }
 
 func decodeNodeLost(addr bidib.Address, data []byte) (NodeLost, error) {
 	var result NodeLost
-	if err := validateDataLength(data, 8); err != nil {
+	if err := validateDataLength(data, 9); err != nil {
 		return result, err
 	}
 	result.Address = addr
-	result.NodeAddress = data[0]
-	copy(result.UniqueID[:], data[1:])
+	result.TableVersion = data[0]
+	result.NodeAddress = data[1]
+	copy(result.UniqueID[:], data[2:])
 	return result, nil
 }
 
@@ -258,15 +258,17 @@ func decodeSysIdentityState(addr bidib.Address, data []byte) (SysIdentityState,
 type SysError struct {
 	BaseMessage
 	Error bidib.ErrorCode
+	// Error specific parameters
+	Params []byte
 }
 
 func (m SysError) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
-	data := []byte{byte(m.Error)}
+	data := append([]byte{byte(m.Error)}, m.Params...)
 	bidib.EncodeMessage(write, bidib.MSG_SYS_ERROR, m.Address, seqNum, data)
 }
 
 func (m SysError) String() string {
-	return fmt.Sprintf("%T addr=%s error=%s", m, m.Address, m.Error)
+	return fmt.Sprintf("%T addr=%s error=%s params=%v", m, m.Address, m.Error, m.Params)
 }
 
 func decodeSysError(addr bidib.Address, data []byte) (SysError, error) {
@@ -276,5 +278,8 @@ func decodeSysError(addr bidib.Address, data []byte) (SysError, error) {
 	}
 	result.Address = addr
 	result.Error = bidib.ErrorCode(data[0])
+	if len(data) > 1 {
+		result.Params = append([]byte(nil), data[1:]...)
+	}
 	return result, nil
 }
@@ -0,0 +1,214 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/binkynet/bidib"
+)
+
+// Query the state of all ports of the selected types.
+// Followed by 2 bytes SELECT (bitmask of port types) and optionally 4 bytes
+// with the (flat) start and end (exclusive) of the port range.
+// The node answers with a MSG_LC_STAT for every port, followed by MSG_LC_NA for port 0xFFFF.
+type LcPortQueryAll struct {
+	BaseMessage
+	Select uint16
+	// Optional port range
+	HasRange bool
+	Start    uint16
+	End      uint16
+}
+
+func (m LcPortQueryAll) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{0, 0}
+	writeUint16(data, m.Select)
+	if m.HasRange {
+		data = append(data, 0, 0, 0, 0)
+		writeUint16(data[2:], m.Start)
+		writeUint16(data[4:], m.End)
+	}
+	bidib.EncodeMessage(write, bidib.MSG_LC_PORT_QUERY_ALL, m.Address, seqNum, data)
+}
+
+func (m LcPortQueryAll) String() string {
+	if m.HasRange {
+		return fmt.Sprintf("%T addr=%s select=0x%04x start=%d end=%d", m, m.Address, m.Select, m.Start, m.End)
+	}
+	return fmt.Sprintf("%T addr=%s select=0x%04x", m, m.Address, m.Select)
+}
+
+func decodeLcPortQueryAll(addr bidib.Address, data []byte) (LcPortQueryAll, error) {
+	var result LcPortQueryAll
+	result.Address = addr
+	switch len(data) {
+	case 0:
+		result.Select = 0xFFFF
+	case 2:
+		result.Select = readUint16(data)
+	case 6:
+		result.Select = readUint16(data)
+		result.HasRange = true
+		result.Start = readUint16(data[2:])
+		result.End = readUint16(data[4:])
+	default:
+		return result, fmt.Errorf("invalid data length; got %d, expected 0, 2 or 6", len(data))
+	}
+	return result, nil
+}
+
+// Set the state of a port. Followed by 3 bytes: PORT (2 bytes), STATE.
+// The meaning of STATE depends on the port type (opcode or value).
+// The node answers with MSG_LC_STAT, MSG_LC_WAIT or MSG_LC_NA.
+type LcOutput struct {
+	BaseMessage
+	Port  LcPort
+	State uint8
+}
+
+func (m LcOutput) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1], m.State}
+	bidib.EncodeMessage(write, bidib.MSG_LC_OUTPUT, m.Address, seqNum, data)
+}
+
+func (m LcOutput) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s state=%d", m, m.Address, m.Port, m.State)
+}
+
+func decodeLcOutput(addr bidib.Address, data []byte) (LcOutput, error) {
+	var result LcOutput
+	if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	result.State = data[2]
+	return result, nil
+}
+
+// Query the state of a single port. Followed by 2 bytes: PORT.
+// The node answers with MSG_LC_STAT or MSG_LC_NA.
+type LcPortQuery struct {
+	BaseMessage
+	Port LcPort
+}
+
+func (m LcPortQuery) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1]}
+	bidib.EncodeMessage(write, bidib.MSG_LC_PORT_QUERY, m.Address, seqNum, data)
+}
+
+func (m LcPortQuery) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s", m, m.Address, m.Port)
+}
+
+func decodeLcPortQuery(addr bidib.Address, data []byte) (LcPortQuery, error) {
+	var result LcPortQuery
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	return result, nil
+}
+
+// Query the configuration of all ports.
+// Optionally followed by 4 bytes with the (flat) start and end (exclusive) of the port range.
+// The node answers with a MSG_LC_CONFIGX for every port.
+type LcConfigXGetAll struct {
+	BaseMessage
+	// Optional port range
+	HasRange bool
+	Start    uint16
+	End      uint16
+}
+
+func (m LcConfigXGetAll) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	var data []byte
+	if m.HasRange {
+		data = []byte{0, 0, 0, 0}
+		writeUint16(data, m.Start)
+		writeUint16(data[2:], m.End)
+	}
+	bidib.EncodeMessage(write, bidib.MSG_LC_CONFIGX_GET_ALL, m.Address, seqNum, data)
+}
+
+func (m LcConfigXGetAll) String() string {
+	if m.HasRange {
+		return fmt.Sprintf("%T addr=%s start=%d end=%d", m, m.Address, m.Start, m.End)
+	}
+	return fmt.Sprintf("%T addr=%s", m, m.Address)
+}
+
+func decodeLcConfigXGetAll(addr bidib.Address, data []byte) (LcConfigXGetAll, error) {
+	var result LcConfigXGetAll
+	result.Address = addr
+	if len(data) == 0 {
+		return result, nil
+	}
+	if err := validateDataLength(data, 4); err != nil {
+		return result, err
+	}
+	result.HasRange = true
+	result.Start = readUint16(data)
+	result.End = readUint16(data[2:])
+	return result, nil
+}
+
+// Write configuration parameters of a port. Followed by 2 bytes PORT and
+// pairs of parameter enum & value.
+// The node answers with MSG_LC_CONFIGX (containing the actual values) or MSG_LC_NA.
+type LcConfigXSet struct {
+	BaseMessage
+	Port   LcPort
+	Params []LcConfigParam
+}
+
+func (m LcConfigXSet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.Port[0], m.Port[1]}, encodeLcConfigParams(m.Params)...)
+	bidib.EncodeMessage(write, bidib.MSG_LC_CONFIGX_SET, m.Address, seqNum, data)
+}
+
+func (m LcConfigXSet) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s params=%v", m, m.Address, m.Port, m.Params)
+}
+
+func decodeLcConfigXSet(addr bidib.Address, data []byte) (LcConfigXSet, error) {
+	var result LcConfigXSet
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	params, err := decodeLcConfigParams(data[2:])
+	if err != nil {
+		return result, err
+	}
+	result.Params = params
+	return result, nil
+}
+
+// Query the configuration of a port. Followed by 2 bytes: PORT.
+// The node answers with MSG_LC_CONFIGX or MSG_LC_NA.
+type LcConfigXGet struct {
+	BaseMessage
+	Port LcPort
+}
+
+func (m LcConfigXGet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1]}
+	bidib.EncodeMessage(write, bidib.MSG_LC_CONFIGX_GET, m.Address, seqNum, data)
+}
+
+func (m LcConfigXGet) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s", m, m.Address, m.Port)
+}
+
+func decodeLcConfigXGet(addr bidib.Address, data []byte) (LcConfigXGet, error) {
+	var result LcConfigXGet
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	return result, nil
+}
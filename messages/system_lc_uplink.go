@@ -0,0 +1,152 @@
+package messages
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binkynet/bidib"
+)
+
+// State of a port. Followed by 3 bytes: PORT (2 bytes), STATE.
+// The meaning of STATE depends on the port type (opcode or value).
+type LcStat struct {
+	BaseMessage
+	Port  LcPort
+	State uint8
+}
+
+func (m LcStat) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1], m.State}
+	bidib.EncodeMessage(write, bidib.MSG_LC_STAT, m.Address, seqNum, data)
+}
+
+func (m LcStat) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s state=%d", m, m.Address, m.Port, m.State)
+}
+
+func decodeLcStat(addr bidib.Address, data []byte) (LcStat, error) {
+	var result LcStat
+	if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	result.State = data[2]
+	return result, nil
+}
+
+// A port is not available (or a command could not be executed).
+// Followed by 2 bytes PORT and optionally 1 byte with the cause.
+type LcNa struct {
+	BaseMessage
+	Port LcPort
+	// Set if the node included a cause
+	HasCause bool
+	Cause    uint8
+}
+
+func (m LcNa) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1]}
+	if m.HasCause {
+		data = append(data, m.Cause)
+	}
+	bidib.EncodeMessage(write, bidib.MSG_LC_NA, m.Address, seqNum, data)
+}
+
+func (m LcNa) String() string {
+	if m.HasCause {
+		return fmt.Sprintf("%T addr=%s port=%s cause=0x%02x", m, m.Address, m.Port, m.Cause)
+	}
+	return fmt.Sprintf("%T addr=%s port=%s", m, m.Address, m.Port)
+}
+
+func decodeLcNa(addr bidib.Address, data []byte) (LcNa, error) {
+	var result LcNa
+	result.Address = addr
+	if len(data) == 2 {
+		result.Port = LcPort{data[0], data[1]}
+	} else if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	} else {
+		result.Port = LcPort{data[0], data[1]}
+		result.HasCause = true
+		result.Cause = data[2]
+	}
+	return result, nil
+}
+
+// A port is executing a command that takes some time.
+// Followed by 3 bytes: PORT (2 bytes), TIME.
+// Bit 7 of TIME selects the unit (0: 100ms, 1: 1s), bits 0-6 hold the value.
+type LcWait struct {
+	BaseMessage
+	Port LcPort
+	Time uint8
+}
+
+// Duration returns the time until the command is complete.
+func (m LcWait) Duration() time.Duration {
+	value := time.Duration(m.Time & 0x7F)
+	if m.Time&0x80 != 0 {
+		return value * time.Second
+	}
+	return value * time.Millisecond * 100
+}
+
+func (m LcWait) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Port[0], m.Port[1], m.Time}
+	bidib.EncodeMessage(write, bidib.MSG_LC_WAIT, m.Address, seqNum, data)
+}
+
+func (m LcWait) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s time=%s", m, m.Address, m.Port, m.Duration())
+}
+
+func decodeLcWait(addr bidib.Address, data []byte) (LcWait, error) {
+	var result LcWait
+	if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	result.Time = data[2]
+	return result, nil
+}
+
+// Configuration of a port. Followed by 2 bytes PORT and pairs of parameter enum & value.
+// If the last parameter is BIDIB_PCFG_CONTINUE, another MSG_LC_CONFIGX for the same port follows.
+type LcConfigX struct {
+	BaseMessage
+	Port   LcPort
+	Params []LcConfigParam
+}
+
+// HasContinuation returns true if another message with parameters of the same port follows.
+func (m LcConfigX) HasContinuation() bool {
+	l := len(m.Params)
+	return l > 0 && m.Params[l-1].Enum == bidib.BIDIB_PCFG_CONTINUE
+}
+
+func (m LcConfigX) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.Port[0], m.Port[1]}, encodeLcConfigParams(m.Params)...)
+	bidib.EncodeMessage(write, bidib.MSG_LC_CONFIGX, m.Address, seqNum, data)
+}
+
+func (m LcConfigX) String() string {
+	return fmt.Sprintf("%T addr=%s port=%s params=%v", m, m.Address, m.Port, m.Params)
+}
+
+func decodeLcConfigX(addr bidib.Address, data []byte) (LcConfigX, error) {
+	var result LcConfigX
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Port = LcPort{data[0], data[1]}
+	params, err := decodeLcConfigParams(data[2:])
+	if err != nil {
+		return result, err
+	}
+	result.Params = params
+	return result, nil
+}
@@ -0,0 +1,147 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/binkynet/bidib"
+)
+
+// Control a macro. Followed by 2 bytes: MACRO, OPCODE (BIDIB_MACRO_*).
+// The node answers with MSG_LC_MACRO_STATE.
+type LcMacroHandle struct {
+	BaseMessage
+	MacroNum uint8
+	Opcode   uint8
+}
+
+func (m LcMacroHandle) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MacroNum, m.Opcode}
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_HANDLE, m.Address, seqNum, data)
+}
+
+func (m LcMacroHandle) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d opcode=%d", m, m.Address, m.MacroNum, m.Opcode)
+}
+
+func decodeLcMacroHandle(addr bidib.Address, data []byte) (LcMacroHandle, error) {
+	var result LcMacroHandle
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.Opcode = data[1]
+	return result, nil
+}
+
+// Write a step of a macro. Followed by 6 bytes: MACRO, ITEM, DELAY, PORT (2 bytes), STATUS.
+// The node answers with MSG_LC_MACRO.
+type LcMacroSet struct {
+	BaseMessage
+	LcMacroStep
+}
+
+func (m LcMacroSet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_SET, m.Address, seqNum, m.encode())
+}
+
+func (m LcMacroSet) String() string {
+	return fmt.Sprintf("%T addr=%s %s", m, m.Address, m.LcMacroStep)
+}
+
+func decodeLcMacroSet(addr bidib.Address, data []byte) (LcMacroSet, error) {
+	var result LcMacroSet
+	step, err := decodeLcMacroStep(data)
+	if err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.LcMacroStep = step
+	return result, nil
+}
+
+// Read a step of a macro. Followed by 2 bytes: MACRO, ITEM.
+// The node answers with MSG_LC_MACRO.
+type LcMacroGet struct {
+	BaseMessage
+	MacroNum uint8
+	Item     uint8
+}
+
+func (m LcMacroGet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MacroNum, m.Item}
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_GET, m.Address, seqNum, data)
+}
+
+func (m LcMacroGet) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d item=%d", m, m.Address, m.MacroNum, m.Item)
+}
+
+func decodeLcMacroGet(addr bidib.Address, data []byte) (LcMacroGet, error) {
+	var result LcMacroGet
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.Item = data[1]
+	return result, nil
+}
+
+// Write a parameter of a macro. Followed by 6 bytes: MACRO, PARA_IDX, VALUE (4 bytes).
+// The node answers with MSG_LC_MACRO_PARA.
+type LcMacroParaSet struct {
+	BaseMessage
+	MacroNum uint8
+	ParaIdx  uint8
+	Value    [4]byte
+}
+
+func (m LcMacroParaSet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.MacroNum, m.ParaIdx}, m.Value[:]...)
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_PARA_SET, m.Address, seqNum, data)
+}
+
+func (m LcMacroParaSet) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d para=%d value=%x", m, m.Address, m.MacroNum, m.ParaIdx, m.Value)
+}
+
+func decodeLcMacroParaSet(addr bidib.Address, data []byte) (LcMacroParaSet, error) {
+	var result LcMacroParaSet
+	if err := validateDataLength(data, 6); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.ParaIdx = data[1]
+	copy(result.Value[:], data[2:])
+	return result, nil
+}
+
+// Read a parameter of a macro. Followed by 2 bytes: MACRO, PARA_IDX.
+// The node answers with MSG_LC_MACRO_PARA.
+type LcMacroParaGet struct {
+	BaseMessage
+	MacroNum uint8
+	ParaIdx  uint8
+}
+
+func (m LcMacroParaGet) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MacroNum, m.ParaIdx}
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_PARA_GET, m.Address, seqNum, data)
+}
+
+func (m LcMacroParaGet) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d para=%d", m, m.Address, m.MacroNum, m.ParaIdx)
+}
+
+func decodeLcMacroParaGet(addr bidib.Address, data []byte) (LcMacroParaGet, error) {
+	var result LcMacroParaGet
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.ParaIdx = data[1]
+	return result, nil
+}
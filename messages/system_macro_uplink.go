@@ -0,0 +1,122 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/binkynet/bidib"
+)
+
+// LcMacroStep is the content of MSG_LC_MACRO and MSG_LC_MACRO_SET.
+// 6 bytes: MACRO, ITEM, DELAY, PORT (2 bytes), STATUS.
+// For system functions, the first port byte is 0xFF and the second byte
+// holds the function (BIDIB_MSYS_*).
+type LcMacroStep struct {
+	MacroNum uint8
+	Item     uint8
+	Delay    uint8
+	Port     LcPort
+	Status   uint8
+}
+
+func (s LcMacroStep) encode() []byte {
+	return []byte{s.MacroNum, s.Item, s.Delay, s.Port[0], s.Port[1], s.Status}
+}
+
+func (s LcMacroStep) String() string {
+	return fmt.Sprintf("macro=%d item=%d delay=%d port=%s status=%d", s.MacroNum, s.Item, s.Delay, s.Port, s.Status)
+}
+
+func decodeLcMacroStep(data []byte) (LcMacroStep, error) {
+	var result LcMacroStep
+	if err := validateDataLength(data, 6); err != nil {
+		return result, err
+	}
+	result.MacroNum = data[0]
+	result.Item = data[1]
+	result.Delay = data[2]
+	result.Port = LcPort{data[3], data[4]}
+	result.Status = data[5]
+	return result, nil
+}
+
+// State of a macro. Followed by 2 bytes: MACRO, STATE (BIDIB_MACRO_*).
+type LcMacroState struct {
+	BaseMessage
+	MacroNum uint8
+	State    uint8
+}
+
+func (m LcMacroState) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MacroNum, m.State}
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_STATE, m.Address, seqNum, data)
+}
+
+func (m LcMacroState) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d state=%d", m, m.Address, m.MacroNum, m.State)
+}
+
+func decodeLcMacroState(addr bidib.Address, data []byte) (LcMacroState, error) {
+	var result LcMacroState
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.State = data[1]
+	return result, nil
+}
+
+// A step of a macro, send in response to MSG_LC_MACRO_GET or MSG_LC_MACRO_SET.
+type LcMacro struct {
+	BaseMessage
+	LcMacroStep
+}
+
+func (m LcMacro) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO, m.Address, seqNum, m.encode())
+}
+
+func (m LcMacro) String() string {
+	return fmt.Sprintf("%T addr=%s %s", m, m.Address, m.LcMacroStep)
+}
+
+func decodeLcMacro(addr bidib.Address, data []byte) (LcMacro, error) {
+	var result LcMacro
+	step, err := decodeLcMacroStep(data)
+	if err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.LcMacroStep = step
+	return result, nil
+}
+
+// A parameter of a macro. Followed by 6 bytes: MACRO, PARA_IDX, VALUE (4 bytes).
+// If the parameter does not exist, VALUE is 0xFFFFFFFF.
+type LcMacroPara struct {
+	BaseMessage
+	MacroNum uint8
+	ParaIdx  uint8
+	Value    [4]byte
+}
+
+func (m LcMacroPara) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.MacroNum, m.ParaIdx}, m.Value[:]...)
+	bidib.EncodeMessage(write, bidib.MSG_LC_MACRO_PARA, m.Address, seqNum, data)
+}
+
+func (m LcMacroPara) String() string {
+	return fmt.Sprintf("%T addr=%s macro=%d para=%d value=%x", m, m.Address, m.MacroNum, m.ParaIdx, m.Value)
+}
+
+func decodeLcMacroPara(addr bidib.Address, data []byte) (LcMacroPara, error) {
+	var result LcMacroPara
+	if err := validateDataLength(data, 6); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MacroNum = data[0]
+	result.ParaIdx = data[1]
+	copy(result.Value[:], data[2:])
+	return result, nil
+}
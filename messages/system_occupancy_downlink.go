@@ -0,0 +1,171 @@
+package messages
+
+import (
+	"fmt"
+
+	"github.com/binkynet/bidib"
+)
+
+// Query the occupancy state of a range of detectors.
+// Followed by 2 bytes: START, END. Both must be a multiple of 8, END is exclusive.
+// The node answers with MSG_BM_MULTIPLE.
+type BmGetRange struct {
+	BaseMessage
+	Start uint8
+	End   uint8
+}
+
+func (m BmGetRange) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Start, m.End}
+	bidib.EncodeMessage(write, bidib.MSG_BM_GET_RANGE, m.Address, seqNum, data)
+}
+
+func (m BmGetRange) String() string {
+	return fmt.Sprintf("%T addr=%s start=%d end=%d", m, m.Address, m.Start, m.End)
+}
+
+func decodeBmGetRange(addr bidib.Address, data []byte) (BmGetRange, error) {
+	var result BmGetRange
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Start = data[0]
+	result.End = data[1]
+	return result, nil
+}
+
+// Query the detected addresses of a range of detectors.
+// Followed by 2 bytes: START, END (exclusive).
+// The node answers with a MSG_BM_ADDRESS for each detector in the range.
+type BmAddrGetRange struct {
+	BaseMessage
+	Start uint8
+	End   uint8
+}
+
+func (m BmAddrGetRange) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Start, m.End}
+	bidib.EncodeMessage(write, bidib.MSG_BM_ADDR_GET_RANGE, m.Address, seqNum, data)
+}
+
+func (m BmAddrGetRange) String() string {
+	return fmt.Sprintf("%T addr=%s start=%d end=%d", m, m.Address, m.Start, m.End)
+}
+
+func decodeBmAddrGetRange(addr bidib.Address, data []byte) (BmAddrGetRange, error) {
+	var result BmAddrGetRange
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Start = data[0]
+	result.End = data[1]
+	return result, nil
+}
+
+// Query the confidence of the occupancy detection.
+// The node answers with MSG_BM_CONFIDENCE.
+type BmGetConfidence struct {
+	BaseMessage
+}
+
+func (m BmGetConfidence) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	bidib.EncodeMessage(write, bidib.MSG_BM_GET_CONFIDENCE, m.Address, seqNum, nil)
+}
+
+func (m BmGetConfidence) String() string {
+	return fmt.Sprintf("%T addr=%s", m, m.Address)
+}
+
+func decodeBmGetConfidence(addr bidib.Address, data []byte) (BmGetConfidence, error) {
+	var result BmGetConfidence
+	if err := validateDataLength(data, 0); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	return result, nil
+}
+
+// Mirror of a MSG_BM_OCC report (secure-ack). Followed by 1 byte MNUM.
+type BmMirrorOcc struct {
+	BaseMessage
+	MNum uint8
+}
+
+func (m BmMirrorOcc) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MNum}
+	bidib.EncodeMessage(write, bidib.MSG_BM_MIRROR_OCC, m.Address, seqNum, data)
+}
+
+func (m BmMirrorOcc) String() string {
+	return fmt.Sprintf("%T addr=%s mnum=%d", m, m.Address, m.MNum)
+}
+
+func decodeBmMirrorOcc(addr bidib.Address, data []byte) (BmMirrorOcc, error) {
+	var result BmMirrorOcc
+	if err := validateDataLength(data, 1); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MNum = data[0]
+	return result, nil
+}
+
+// Mirror of a MSG_BM_FREE report (secure-ack). Followed by 1 byte MNUM.
+type BmMirrorFree struct {
+	BaseMessage
+	MNum uint8
+}
+
+func (m BmMirrorFree) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MNum}
+	bidib.EncodeMessage(write, bidib.MSG_BM_MIRROR_FREE, m.Address, seqNum, data)
+}
+
+func (m BmMirrorFree) String() string {
+	return fmt.Sprintf("%T addr=%s mnum=%d", m, m.Address, m.MNum)
+}
+
+func decodeBmMirrorFree(addr bidib.Address, data []byte) (BmMirrorFree, error) {
+	var result BmMirrorFree
+	if err := validateDataLength(data, 1); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MNum = data[0]
+	return result, nil
+}
+
+// Mirror of a MSG_BM_MULTIPLE report (secure-ack).
+// Followed by 2 bytes BASE, SIZE and SIZE/8 bytes of occupancy bits.
+type BmMirrorMultiple struct {
+	BaseMessage
+	Base uint8
+	Size uint8
+	Data []byte
+}
+
+func (m BmMirrorMultiple) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.Base, m.Size}, m.Data...)
+	bidib.EncodeMessage(write, bidib.MSG_BM_MIRROR_MULTIPLE, m.Address, seqNum, data)
+}
+
+func (m BmMirrorMultiple) String() string {
+	return fmt.Sprintf("%T addr=%s base=%d size=%d data=%x", m, m.Address, m.Base, m.Size, m.Data)
+}
+
+func decodeBmMirrorMultiple(addr bidib.Address, data []byte) (BmMirrorMultiple, error) {
+	var result BmMirrorMultiple
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Base = data[0]
+	result.Size = data[1]
+	if err := validateDataLength(data[2:], (int(result.Size)+7)/8); err != nil {
+		return result, err
+	}
+	result.Data = append([]byte(nil), data[2:]...)
+	return result, nil
+}
@@ -7,6 +7,180 @@ import (
 	"github.com/binkynet/bidib"
 )
 
+// Occupancy of a detector. Followed by 1 byte MNUM and optionally 2 bytes
+// with a timestamp (TIME_L, TIME_H) when FEATURE_BM_TIMESTAMP_ON is set.
+type BmOcc struct {
+	BaseMessage
+	// Local number of the occupancy detector. Value range 0…127
+	MNum uint8
+	// Set if the node included a timestamp
+	HasTimeStamp bool
+	// Timestamp of the node (unit: 1/16 ms, wrapping)
+	TimeStamp uint16
+}
+
+func (m BmOcc) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MNum}
+	if m.HasTimeStamp {
+		data = append(data, 0, 0)
+		writeUint16(data[1:], m.TimeStamp)
+	}
+	bidib.EncodeMessage(write, bidib.MSG_BM_OCC, m.Address, seqNum, data)
+}
+
+func (m BmOcc) String() string {
+	if m.HasTimeStamp {
+		return fmt.Sprintf("%T addr=%s mnum=%d time=%d", m, m.Address, m.MNum, m.TimeStamp)
+	}
+	return fmt.Sprintf("%T addr=%s mnum=%d", m, m.Address, m.MNum)
+}
+
+func decodeBmOcc(addr bidib.Address, data []byte) (BmOcc, error) {
+	var result BmOcc
+	result.Address = addr
+	if len(data) == 1 {
+		result.MNum = data[0]
+	} else if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	} else {
+		result.MNum = data[0]
+		result.HasTimeStamp = true
+		result.TimeStamp = readUint16(data[1:])
+	}
+	return result, nil
+}
+
+// A detector became free. Followed by 1 byte MNUM.
+type BmFree struct {
+	BaseMessage
+	// Local number of the occupancy detector. Value range 0…127
+	MNum uint8
+}
+
+func (m BmFree) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MNum}
+	bidib.EncodeMessage(write, bidib.MSG_BM_FREE, m.Address, seqNum, data)
+}
+
+func (m BmFree) String() string {
+	return fmt.Sprintf("%T addr=%s mnum=%d", m, m.Address, m.MNum)
+}
+
+func decodeBmFree(addr bidib.Address, data []byte) (BmFree, error) {
+	var result BmFree
+	if err := validateDataLength(data, 1); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MNum = data[0]
+	return result, nil
+}
+
+// Occupancy of a range of detectors. Followed by 2 bytes BASE, SIZE and
+// SIZE/8 bytes of occupancy bits. The LSB of the first byte is detector BASE.
+type BmMultiple struct {
+	BaseMessage
+	// Number of the first detector
+	Base uint8
+	// Number of detectors (multiple of 8)
+	Size uint8
+	// Occupancy bits
+	Data []byte
+}
+
+// IsOccupied returns true if the detector with given index (relative to Base) is occupied.
+func (m BmMultiple) IsOccupied(index uint8) bool {
+	byteIdx := int(index / 8)
+	if byteIdx >= len(m.Data) {
+		return false
+	}
+	return m.Data[byteIdx]&(1<<(index%8)) != 0
+}
+
+func (m BmMultiple) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := append([]byte{m.Base, m.Size}, m.Data...)
+	bidib.EncodeMessage(write, bidib.MSG_BM_MULTIPLE, m.Address, seqNum, data)
+}
+
+func (m BmMultiple) String() string {
+	return fmt.Sprintf("%T addr=%s base=%d size=%d data=%x", m, m.Address, m.Base, m.Size, m.Data)
+}
+
+func decodeBmMultiple(addr bidib.Address, data []byte) (BmMultiple, error) {
+	var result BmMultiple
+	if err := validateMinDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Base = data[0]
+	result.Size = data[1]
+	if err := validateDataLength(data[2:], (int(result.Size)+7)/8); err != nil {
+		return result, err
+	}
+	result.Data = append([]byte(nil), data[2:]...)
+	return result, nil
+}
+
+// Confidence of the occupancy detection. Followed by 3 bytes VOID, FREEZE, NOSIGNAL.
+// A value of 0 means that the detection is reliable, other values signal a problem
+// (e.g. the detectors have no valid data, or their state is frozen).
+type BmConfidence struct {
+	BaseMessage
+	Void     uint8
+	Freeze   uint8
+	NoSignal uint8
+}
+
+func (m BmConfidence) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.Void, m.Freeze, m.NoSignal}
+	bidib.EncodeMessage(write, bidib.MSG_BM_CONFIDENCE, m.Address, seqNum, data)
+}
+
+func (m BmConfidence) String() string {
+	return fmt.Sprintf("%T addr=%s void=%d freeze=%d nosignal=%d", m, m.Address, m.Void, m.Freeze, m.NoSignal)
+}
+
+func decodeBmConfidence(addr bidib.Address, data []byte) (BmConfidence, error) {
+	var result BmConfidence
+	if err := validateDataLength(data, 3); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.Void = data[0]
+	result.Freeze = data[1]
+	result.NoSignal = data[2]
+	return result, nil
+}
+
+// Current measured by a detector. Followed by 2 bytes MNUM, CURRENT.
+// The current uses the same coding as MSG_BOOST_DIAGNOSTIC.
+type BmCurrent struct {
+	BaseMessage
+	// Local number of the occupancy detector. Value range 0…127
+	MNum    uint8
+	Current bidib.Current
+}
+
+func (m BmCurrent) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
+	data := []byte{m.MNum, uint8(m.Current)}
+	bidib.EncodeMessage(write, bidib.MSG_BM_CURRENT, m.Address, seqNum, data)
+}
+
+func (m BmCurrent) String() string {
+	return fmt.Sprintf("%T addr=%s mnum=%d current=%s", m, m.Address, m.MNum, m.Current)
+}
+
+func decodeBmCurrent(addr bidib.Address, data []byte) (BmCurrent, error) {
+	var result BmCurrent
+	if err := validateDataLength(data, 2); err != nil {
+		return result, err
+	}
+	result.Address = addr
+	result.MNum = data[0]
+	result.Current = bidib.Current(data[1])
+	return result, nil
+}
+
 // CV-message, followed by 5 bytes: ADDRL, ADDRH, CVL, CVH, DAT
 type BmCv struct {
 	BaseMessage
@@ -126,7 +300,7 @@ type BmAddress struct {
 }
 
 func (m BmAddress) Encode(write func(uint8), seqNum bidib.SequenceNumber) {
-	data := make([]byte, 1+len(m.DccAddresses))
+	data := make([]byte, 1+2*len(m.DccAddresses))
 	data[0] = m.MNum
 	idx := 1
 	for _, dccAddr := range m.DccAddresses {
@@ -0,0 +1,35 @@
+package bidib
+
+import "fmt"
+
+// ProtocolVersion is the BiDiB protocol version supported by a node.
+type ProtocolVersion struct {
+	Major uint8
+	Minor uint8
+}
+
+var (
+	// Protocol version that introduced MSG_BOOST_DIAGNOSTIC (deprecating MSG_BOOST_CURRENT)
+	ProtocolVersionBoostDiagnostic = ProtocolVersion{Major: 0, Minor: 10}
+)
+
+// IsKnown returns true if the version has been reported by a node.
+func (v ProtocolVersion) IsKnown() bool {
+	return v != ProtocolVersion{}
+}
+
+// AtLeast returns true if this version is equal to or newer than the given version.
+func (v ProtocolVersion) AtLeast(other ProtocolVersion) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+	return v.Minor >= other.Minor
+}
+
+// String converts the version into a readable string
+func (v ProtocolVersion) String() string {
+	if !v.IsKnown() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
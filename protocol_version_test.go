@@ -0,0 +1,23 @@
+package bidib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolVersionAtLeast(t *testing.T) {
+	v := ProtocolVersion{Major: 0, Minor: 10}
+	assert.True(t, v.AtLeast(ProtocolVersionBoostDiagnostic))
+	assert.True(t, v.AtLeast(ProtocolVersion{Major: 0, Minor: 8}))
+	assert.False(t, v.AtLeast(ProtocolVersion{Major: 0, Minor: 11}))
+	assert.False(t, v.AtLeast(ProtocolVersion{Major: 1, Minor: 0}))
+	assert.True(t, ProtocolVersion{Major: 1, Minor: 0}.AtLeast(v))
+	assert.False(t, ProtocolVersion{Major: 0, Minor: 9}.AtLeast(ProtocolVersionBoostDiagnostic))
+}
+
+func TestProtocolVersionString(t *testing.T) {
+	assert.Equal(t, "0.10", ProtocolVersion{Major: 0, Minor: 10}.String())
+	assert.Equal(t, "unknown", ProtocolVersion{}.String())
+	assert.False(t, ProtocolVersion{}.IsKnown())
+}
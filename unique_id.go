@@ -40,7 +40,57 @@ func (uid UniqueID) ProductID() uint32 {
 	return binary.LittleEndian.Uint32(uid[3:])
 }
 
+// Default number of bits of the product ID that identify the product.
+const DefaultRelevantPidBits = 16
+
+// Split the 32-bit product ID into a product part (lower pidBits) and a serial number (upper bits).
+// If pidBits is 0, DefaultRelevantPidBits is used.
+func (uid UniqueID) splitProductID(pidBits uint8) (uint32, uint32) {
+	if pidBits == 0 {
+		pidBits = DefaultRelevantPidBits
+	}
+	if pidBits >= 32 {
+		return uid.ProductID(), 0
+	}
+	pid := uid.ProductID()
+	return pid & ((1 << pidBits) - 1), pid >> pidBits
+}
+
+// Product returns the product part of the product ID, using given number of relevant product bits.
+// If pidBits is 0, DefaultRelevantPidBits is used.
+func (uid UniqueID) Product(pidBits uint8) uint32 {
+	product, _ := uid.splitProductID(pidBits)
+	return product
+}
+
+// SerialNumber returns the serial number part of the product ID, using given number of relevant product bits.
+// If pidBits is 0, DefaultRelevantPidBits is used.
+func (uid UniqueID) SerialNumber(pidBits uint8) uint32 {
+	_, serial := uid.splitProductID(pidBits)
+	return serial
+}
+
+// VendorName returns the name of the manufacturer of the node.
+func (uid UniqueID) VendorName() string {
+	return VendorName(uid.VendorID())
+}
+
+// ProductName returns the name of the product, using given number of relevant product bits.
+// Returns name, found
+func (uid UniqueID) ProductName(pidBits uint8) (string, bool) {
+	return ProductName(uid.VendorID(), uid.Product(pidBits))
+}
+
+// Describe returns a short human readable description of the node, e.g. "Fichtelbahn GBM16T #42".
+// If pidBits is 0, DefaultRelevantPidBits is used.
+func (uid UniqueID) Describe(pidBits uint8) string {
+	if name, found := uid.ProductName(pidBits); found {
+		return fmt.Sprintf("%s #%d", name, uid.SerialNumber(pidBits))
+	}
+	return fmt.Sprintf("%s 0x%x #%d", uid.VendorName(), uid.Product(pidBits), uid.SerialNumber(pidBits))
+}
+
 // String convers to a human readable string
 func (uid UniqueID) String() string {
-	return fmt.Sprintf("class=[%s], vendor=0x%02x, product=0x%04x", uid.ClassID(), uid.VendorID(), uid.ProductID())
+	return fmt.Sprintf("class=[%s], vendor=%s, product=0x%04x, serial=%d", uid.ClassID(), uid.VendorName(), uid.Product(0), uid.SerialNumber(0))
 }
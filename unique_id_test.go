@@ -0,0 +1,44 @@
+package bidib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueIDProduct(t *testing.T) {
+	uid := UniqueID{0x40, 0, 13, 0x34, 0x12, 42, 0}
+	assert.Equal(t, uint32(0x1234), uid.Product(0))
+	assert.Equal(t, uint32(42), uid.SerialNumber(0))
+	assert.Equal(t, uint32(0x1234), uid.Product(16))
+	assert.Equal(t, uint32(0x34), uid.Product(8))
+	assert.Equal(t, uint32(0x2a12), uid.SerialNumber(8))
+	assert.Equal(t, uint32(0x2a1234), uid.Product(32))
+	assert.Equal(t, uint32(0), uid.SerialNumber(32))
+}
+
+func TestUniqueIDDescribe(t *testing.T) {
+	uid := UniqueID{0x40, 0, 13, 0x78, 0x56, 42, 0}
+	assert.Equal(t, "DIY", uid.VendorName())
+	assert.Equal(t, "DIY 0x5678 #42", uid.Describe(0))
+
+	registerTestProduct(t, 13, 0x5678, "Fichtelbahn GBM16T")
+	assert.Equal(t, "Fichtelbahn GBM16T #42", uid.Describe(0))
+	_, found := uid.ProductName(8)
+	assert.False(t, found)
+
+	assert.Equal(t, "0xfe", VendorName(0xfe))
+}
+
+// registerTestProduct registers a product name for the duration of the test.
+func registerTestProduct(t *testing.T, vendorID uint8, productID uint32, name string) {
+	productTables.mutex.Lock()
+	saved := productTables.tables
+	productTables.mutex.Unlock()
+	t.Cleanup(func() {
+		productTables.mutex.Lock()
+		defer productTables.mutex.Unlock()
+		productTables.tables = saved
+	})
+	RegisterProduct(vendorID, productID, name)
+}
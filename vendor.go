@@ -0,0 +1,97 @@
+package bidib
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Manufacturer names by NMRA manufacturer ID
+var vendorNames = map[uint8]string{
+	1:   "CVP Products",
+	11:  "NCE",
+	13:  "DIY",
+	62:  "Tams Elektronik",
+	78:  "Train-O-Matic",
+	85:  "Uhlenbrock",
+	97:  "Doehler & Haass",
+	99:  "Lenz",
+	109: "Viessmann",
+	113: "QS Industries",
+	117: "CT Elektronik",
+	123: "Massoth",
+	129: "Digitrax",
+	131: "Trix/Maerklin",
+	141: "SoundTraxx",
+	143: "Model Rectifier Corp",
+	145: "Zimo",
+	151: "ESU",
+	153: "Train Control Systems",
+	157: "Kuehn",
+	161: "Roco",
+	162: "Piko",
+}
+
+// VendorName returns the name of the manufacturer with given NMRA manufacturer ID.
+// If the manufacturer is unknown, a hex representation of the ID is returned.
+func VendorName(vendorID uint8) string {
+	if name, found := vendorNames[vendorID]; found {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", vendorID)
+}
+
+// ProductTable is used to lookup human readable product names.
+type ProductTable interface {
+	// ProductName returns the name of the product with given vendor & product ID.
+	// Returns name, found
+	ProductName(vendorID uint8, productID uint32) (string, bool)
+}
+
+// ProductMap is a simple ProductTable for a single vendor, keyed by product ID.
+type ProductMap struct {
+	VendorID uint8
+	Products map[uint32]string
+}
+
+// ProductName returns the name of the product with given vendor & product ID.
+func (pm ProductMap) ProductName(vendorID uint8, productID uint32) (string, bool) {
+	if vendorID != pm.VendorID {
+		return "", false
+	}
+	name, found := pm.Products[productID]
+	return name, found
+}
+
+var productTables struct {
+	mutex  sync.RWMutex
+	tables []ProductTable
+}
+
+// RegisterProductTable adds a product table that is used to lookup product names.
+// Tables registered later take precedence over earlier tables.
+func RegisterProductTable(table ProductTable) {
+	productTables.mutex.Lock()
+	defer productTables.mutex.Unlock()
+	productTables.tables = append([]ProductTable{table}, productTables.tables...)
+}
+
+// RegisterProduct adds a single product name.
+func RegisterProduct(vendorID uint8, productID uint32, name string) {
+	RegisterProductTable(ProductMap{
+		VendorID: vendorID,
+		Products: map[uint32]string{productID: name},
+	})
+}
+
+// ProductName returns the name of the product with given vendor & product ID.
+// Returns name, found
+func ProductName(vendorID uint8, productID uint32) (string, bool) {
+	productTables.mutex.RLock()
+	defer productTables.mutex.RUnlock()
+	for _, t := range productTables.tables {
+		if name, found := t.ProductName(vendorID, productID); found {
+			return name, true
+		}
+	}
+	return "", false
+}
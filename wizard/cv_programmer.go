@@ -122,15 +122,12 @@ func (m *CVProgrammer) Update(msg tea.Msg) tea.Cmd {
 			}
 			cmds = append(cmds, m.updateFocus()...)
 		}
-	case nodeChangedMsg:
-		switch msg := msg.Payload.(type) {
-		case host.ProgramOnMainOptions:
-			if m.pomOpts.DccAddress == msg.DccAddress && m.pomOpts.Cv == msg.Cv {
-				m.pomOpts.Data = msg.Data
-				m.valueBox.setValue(int(msg.Data))
-			}
-			return nil
+	case pomResultMsg:
+		if m.pomOpts.DccAddress == msg.DccAddress && m.pomOpts.Cv == msg.Cv {
+			m.pomOpts.Data = msg.Data
+			m.valueBox.setValue(int(msg.Data))
 		}
+		return nil
 	}
 
 	for _, im := range m.inputs {
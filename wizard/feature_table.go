@@ -15,8 +15,9 @@ func NewFeatureTable(n *host.Node) FeatureTable {
 		node: n,
 		table: table.New(
 			table.WithColumns([]table.Column{
-				{"Feature", 40},
-				{"Value", 10},
+				{Title: "Feature", Width: 40},
+				{Title: "Value", Width: 12},
+				{Title: "Description", Width: 50},
 			}),
 			table.WithFocused(true),
 		),
@@ -36,7 +37,11 @@ func (m *FeatureTable) reloadTableRows() {
 	var items []table.Row
 	for id := bidib.FeatureID(0); id < 255; id++ {
 		if value, found := m.node.GetFeature(id); found {
-			items = append(items, table.Row{id.String(), strconv.Itoa(int(value))})
+			if info, found := id.Info(); found {
+				items = append(items, table.Row{id.String(), info.Format(value), info.Description})
+			} else {
+				items = append(items, table.Row{id.String(), strconv.Itoa(int(value)), ""})
+			}
 		}
 	}
 	m.table.SetRows(items)
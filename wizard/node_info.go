@@ -3,6 +3,7 @@ package wizard
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -42,9 +43,77 @@ type NodeInfo struct {
 func (m *NodeInfo) reloadInfo() {
 	b := strings.Builder{}
 	if m.node != nil {
+		if name := m.node.ProductName(); name != "" {
+			b.WriteString(fmt.Sprintf("Product Name: %s\n", name))
+		}
+		if name := m.node.UserName(); name != "" {
+			b.WriteString(fmt.Sprintf("User Name: %s\n", name))
+		}
+		if v := m.node.ProtocolVersion(); v.IsKnown() {
+			b.WriteString(fmt.Sprintf("Protocol Version: %s\n", v))
+		}
+		if versions := m.node.SoftwareVersions(); len(versions) > 0 {
+			b.WriteString(fmt.Sprintf("Software Version: %s\n", versions[0]))
+			for i, v := range versions[1:] {
+				b.WriteString(fmt.Sprintf("Subsystem %d Version: %s\n", i+1, v))
+			}
+		}
+		if health := m.node.Health(); health != host.HealthUnknown {
+			b.WriteString(fmt.Sprintf("Health: %s (latency %s)\n", health, m.node.Latency().Last))
+		}
+		if stall := m.node.StallInfo(); stall.Stalled {
+			b.WriteString(fmt.Sprintf("Stalled: %s (%d messages held)\n", stall.Duration.Round(time.Millisecond), stall.QueueDepth))
+		}
+		if m.node.IsIdentifying() {
+			b.WriteString("Identifying\n")
+		}
 		if cs := m.node.Cs(); cs != nil {
 			b.WriteString(fmt.Sprintf("DCC Generator State: %s\n", cs.GetState()))
 		}
+		if bm := m.node.Bm(); bm != nil {
+			var occupied []string
+			for _, d := range bm.Detectors() {
+				if d.Occupied {
+					occupied = append(occupied, fmt.Sprintf("%d", d.Number))
+				}
+			}
+			b.WriteString(fmt.Sprintf("Detectors: %d\n", bm.Size()))
+			b.WriteString(fmt.Sprintf("Occupied: %s\n", strings.Join(occupied, ", ")))
+			if c := bm.GetConfidence(); !c.IsReliable() {
+				b.WriteString(fmt.Sprintf("Confidence: void=%d freeze=%d nosignal=%d\n", c.Void, c.Freeze, c.NoSignal))
+			}
+		}
+		if acc := m.node.Accessory(); acc != nil {
+			b.WriteString(fmt.Sprintf("Accessories: %d\n", acc.Count()))
+			for _, a := range acc.Accessories() {
+				state := fmt.Sprintf("aspect %d/%d", a.Aspect, a.Total)
+				if a.Error != nil {
+					state = a.Error.Error()
+				} else if a.InProgress {
+					state += " (moving)"
+				}
+				b.WriteString(fmt.Sprintf("  Accessory %d: %s\n", a.Number, state))
+			}
+		}
+		if lc := m.node.Lc(); lc != nil {
+			ports := lc.Ports()
+			b.WriteString(fmt.Sprintf("Ports: %d\n", len(ports)))
+			for _, p := range ports {
+				state := fmt.Sprintf("state %d", p.State)
+				if p.Error != nil {
+					state = p.Error.Error()
+				} else if p.Waiting {
+					state += " (waiting)"
+				}
+				b.WriteString(fmt.Sprintf("  Port %s (%s): %s\n", p.Address, p.Type, state))
+			}
+		}
+		if bst := m.node.Bst(); bst != nil {
+			b.WriteString(fmt.Sprintf("Booster State: %s\n", bst.GetState()))
+			b.WriteString(fmt.Sprintf("Booster Current: %s\n", bst.GetCurrent()))
+			b.WriteString(fmt.Sprintf("Booster Voltage: %s\n", bst.GetVoltage()))
+			b.WriteString(fmt.Sprintf("Booster Temperature: %s\n", bst.GetTemperature()))
+		}
 	}
 	m.view.SetContent(b.String())
 }
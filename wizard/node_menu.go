@@ -46,6 +46,7 @@ func (i nodeMenuItem) FilterValue() string { return i.Title() }
 type (
 	nodeMenuItemReset            struct{ nodeMenuItem }
 	nodeMenuItemShowFeatures     struct{ nodeMenuItem }
+	nodeMenuItemIdentify         struct{ nodeMenuItem }
 	nodeMenuItemShowDriversCab   struct{ nodeMenuItem }
 	nodeMenuItemShowCVProgrammer struct{ nodeMenuItem }
 	nodeMenuItemCsOff            struct{ nodeMenuItem }
@@ -58,6 +59,11 @@ func (m *NodeMenu) reloadListItems() {
 	var items []list.Item
 	if m.node != nil {
 		items = append(items, nodeMenuItemShowFeatures{"Show features"})
+		if m.node.IsIdentifying() {
+			items = append(items, nodeMenuItemIdentify{"Identify Off"})
+		} else {
+			items = append(items, nodeMenuItemIdentify{"Identify On"})
+		}
 		if m.node.Cs() != nil {
 			items = append(items,
 				nodeMenuItemCsOff{"DCC Generator Off"},
@@ -19,6 +19,8 @@ func NewNodeTree(h host.Host) NodeTree {
 		driversCab:   NewDriversCab(nil),
 		cvProgrammer: NewCVProgrammer(nil),
 		nodeChanges:  make(chan nodeChangedMsg, 64),
+		identifies:   make(chan identifyChangedMsg, 16),
+		pomResults:   make(chan pomResultMsg, 16),
 	}
 	m.list.Title = "Nodes"
 	m.list.SetShowStatusBar(false)
@@ -59,6 +61,8 @@ type NodeTree struct {
 	node          *host.Node
 	list          list.Model
 	nodeChanges   chan nodeChangedMsg
+	identifies    chan identifyChangedMsg
+	pomResults    chan pomResultMsg
 	featureTable  FeatureTable
 	menu          NodeMenu
 	info          NodeInfo
@@ -94,19 +98,41 @@ func (m *NodeTree) reloadListItems() {
 
 type selectCurrentNodeMsg *host.Node
 type nodeChangedMsg host.NodeEvent
+type identifyChangedMsg host.IdentifyEvent
+type pomResultMsg host.PomResultEvent
 
 func (m NodeTree) Init() tea.Cmd {
 	m.host.RegisterNodeChanged(func(n host.NodeEvent) {
 		m.nodeChanges <- nodeChangedMsg(n)
 	})
+	m.host.RegisterIdentifyChanged(func(e host.IdentifyEvent) {
+		m.identifies <- identifyChangedMsg(e)
+	})
+	m.host.RegisterPomResult(func(e host.PomResultEvent) {
+		m.pomResults <- pomResultMsg(e)
+	})
 	return tea.Batch(
 		func() tea.Msg {
 			return selectCurrentNodeMsg(m.host.GetRootNode())
 		},
 		m.onNodeChanged(),
+		m.onIdentifyChanged(),
+		m.onPomResult(),
 	)
 }
 
+func (m NodeTree) onPomResult() tea.Cmd {
+	return func() tea.Msg {
+		return <-m.pomResults
+	}
+}
+
+func (m NodeTree) onIdentifyChanged() tea.Cmd {
+	return func() tea.Msg {
+		return <-m.identifies
+	}
+}
+
 func (m NodeTree) onNodeChanged() tea.Cmd {
 	return func() tea.Msg {
 		return <-m.nodeChanges
@@ -158,6 +184,11 @@ func (m NodeTree) Update(msg tea.Msg) (NodeTree, tea.Cmd) {
 		m.getSelectedNode().Reset()
 		m.state = nodeTreeStateTree
 		return m, nil
+	case nodeMenuItemIdentify:
+		n := m.getSelectedNode()
+		n.Identify(!n.IsIdentifying())
+		m.state = nodeTreeStateTree
+		return m, nil
 	case nodeMenuItemShowFeatures:
 		return m.showFeatures()
 	case nodeMenuItemShowDriversCab:
@@ -179,13 +210,18 @@ func (m NodeTree) Update(msg tea.Msg) (NodeTree, tea.Cmd) {
 	case selectCurrentNodeMsg:
 		m.node = msg
 		m.reloadListItems()
+	case identifyChangedMsg:
+		if msg.Identifying && m.state == nodeTreeStateTree {
+			m.jumpToNode(msg.Node)
+		}
+		cmds = append(cmds, m.onIdentifyChanged())
 	case nodeChangedMsg:
 		m.reloadListItems()
 		m.info.reloadInfo()
-		if msg.Payload != nil {
-			cmds = append(cmds, m.updateCVProgrammer(msg))
-		}
 		cmds = append(cmds, m.onNodeChanged())
+	case pomResultMsg:
+		cmds = append(cmds, m.updateCVProgrammer(msg))
+		cmds = append(cmds, m.onPomResult())
 	default:
 		cmds = append(cmds, m.updateList(msg))
 		cmds = append(cmds, m.updateFeatureTable(msg))
@@ -295,6 +331,24 @@ func (m *NodeTree) applyLayout() {
 	m.driversCab.SetSize(m.width, m.height)
 }
 
+// jumpToNode shows the level containing the given node and selects it.
+func (m *NodeTree) jumpToNode(n *host.Node) {
+	m.node = n
+	if n.Address.HasParent() {
+		if parent, ok := m.host.GetNode(n.Address.Parent()); ok {
+			m.node = parent
+		}
+	}
+	m.reloadListItems()
+	for idx, item := range m.list.Items() {
+		if item.(nodeTreeItem).node == n && item.(nodeTreeItem).role == "" {
+			m.list.Select(idx)
+			break
+		}
+	}
+	m.info.SetNode(m.getSelectedNode())
+}
+
 // Returns the currently selected node (if any)
 func (m *NodeTree) getSelectedNode() *host.Node {
 	if item := m.list.SelectedItem(); item != nil {
@@ -24,6 +24,9 @@ func (i nodeTreeItem) Description() string {
 	if len(i.role) != 0 {
 		return i.role
 	}
-	return i.node.UniqueID.String()
+	if name := i.node.UserName(); name != "" {
+		return name + " (" + i.node.Description() + ")"
+	}
+	return i.node.Description()
 }
 func (i nodeTreeItem) FilterValue() string { return i.Title() }